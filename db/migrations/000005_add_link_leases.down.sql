@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_lease_id_links;
+ALTER TABLE links DROP COLUMN lease_expires_at;
+ALTER TABLE links DROP COLUMN lease_id;
@@ -0,0 +1,5 @@
+ALTER TABLE links ADD COLUMN lease_id TEXT;
+ALTER TABLE links ADD COLUMN lease_expires_at DATETIME;
+
+-- Create index on lease_id in links table
+CREATE INDEX IF NOT EXISTS idx_lease_id_links ON links(lease_id);
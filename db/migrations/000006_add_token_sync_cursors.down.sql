@@ -0,0 +1,2 @@
+ALTER TABLE tokens DROP COLUMN synced_at;
+ALTER TABLE tokens DROP COLUMN sync_cursor;
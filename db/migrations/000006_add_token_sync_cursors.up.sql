@@ -0,0 +1,2 @@
+ALTER TABLE tokens ADD COLUMN sync_cursor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tokens ADD COLUMN synced_at DATETIME;
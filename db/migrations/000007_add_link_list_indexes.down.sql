@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_id_domain_links;
+DROP INDEX IF EXISTS idx_user_id_bookmarked_at_links;
+ALTER TABLE links DROP COLUMN domain;
@@ -0,0 +1,18 @@
+ALTER TABLE links ADD COLUMN domain TEXT;
+
+-- Backfill the domain of existing links from their url
+UPDATE links SET domain = LOWER(
+    CASE
+        WHEN instr(substr(url, instr(url, '://') + 3), '/') > 0
+        THEN substr(substr(url, instr(url, '://') + 3), 1, instr(substr(url, instr(url, '://') + 3), '/') - 1)
+        ELSE substr(url, instr(url, '://') + 3)
+    END
+);
+UPDATE links SET domain = substr(domain, 1, instr(domain, ':') - 1) WHERE instr(domain, ':') > 0;
+UPDATE links SET domain = substr(domain, 5) WHERE domain LIKE 'www.%';
+
+-- Create index for keyset pagination over links
+CREATE INDEX IF NOT EXISTS idx_user_id_bookmarked_at_links ON links(user_id, unixepoch(bookmarked_at), id);
+
+-- Create index on user_id and domain in links table
+CREATE INDEX IF NOT EXISTS idx_user_id_domain_links ON links(user_id, domain);
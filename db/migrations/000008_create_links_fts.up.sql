@@ -0,0 +1,32 @@
+-- Full-text index over links, kept in sync with the links table by triggers.
+-- Requires go-sqlite3 to be built with the sqlite_fts5 tag.
+CREATE VIRTUAL TABLE IF NOT EXISTS links_fts USING fts5(
+    title,
+    note,
+    url,
+    tags,
+    content='links',
+    content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2',
+    prefix='2 3'
+);
+
+CREATE TRIGGER IF NOT EXISTS links_fts_after_insert AFTER INSERT ON links BEGIN
+    INSERT INTO links_fts (rowid, title, note, url, tags)
+    VALUES (new.id, new.title, new.note, new.url, new.tags);
+END;
+
+CREATE TRIGGER IF NOT EXISTS links_fts_after_delete AFTER DELETE ON links BEGIN
+    INSERT INTO links_fts (links_fts, rowid, title, note, url, tags)
+    VALUES ('delete', old.id, old.title, old.note, old.url, old.tags);
+END;
+
+CREATE TRIGGER IF NOT EXISTS links_fts_after_update AFTER UPDATE OF title, note, url, tags ON links BEGIN
+    INSERT INTO links_fts (links_fts, rowid, title, note, url, tags)
+    VALUES ('delete', old.id, old.title, old.note, old.url, old.tags);
+    INSERT INTO links_fts (rowid, title, note, url, tags)
+    VALUES (new.id, new.title, new.note, new.url, new.tags);
+END;
+
+-- Index the links that already exist
+INSERT INTO links_fts (links_fts) VALUES ('rebuild');
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS tags_after_delete;
+DROP TRIGGER IF EXISTS links_after_delete_tags;
+DROP TRIGGER IF EXISTS tags_after_update;
+DROP TRIGGER IF EXISTS link_tags_after_delete;
+DROP TRIGGER IF EXISTS link_tags_after_insert;
+DROP INDEX IF EXISTS idx_tag_id_link_tags;
+DROP TABLE IF EXISTS link_tags;
+DROP TABLE IF EXISTS tags;
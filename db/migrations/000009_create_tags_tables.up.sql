@@ -0,0 +1,99 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS link_tags (
+    link_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (link_id, tag_id),
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- Create index on tag_id in link_tags table
+CREATE INDEX IF NOT EXISTS idx_tag_id_link_tags ON link_tags(tag_id);
+
+-- links.tags is kept as a sorted, comma-separated copy of a link's tags so
+-- that the full-text index and legacy clients can keep reading it.
+CREATE TRIGGER IF NOT EXISTS link_tags_after_insert AFTER INSERT ON link_tags BEGIN
+    UPDATE links SET tags = (
+        SELECT group_concat(name, ',') FROM (
+            SELECT t.name FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = new.link_id
+            ORDER BY t.name
+        )
+    )
+    WHERE id = new.link_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS link_tags_after_delete AFTER DELETE ON link_tags BEGIN
+    UPDATE links SET tags = (
+        SELECT group_concat(name, ',') FROM (
+            SELECT t.name FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = old.link_id
+            ORDER BY t.name
+        )
+    )
+    WHERE id = old.link_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_after_update AFTER UPDATE OF name ON tags BEGIN
+    UPDATE links SET tags = (
+        SELECT group_concat(name, ',') FROM (
+            SELECT t.name FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = links.id
+            ORDER BY t.name
+        )
+    )
+    WHERE id IN (SELECT link_id FROM link_tags WHERE tag_id = new.id);
+END;
+
+-- Foreign keys are not enforced on our connections, so clean up the join
+-- table explicitly when either side is deleted.
+CREATE TRIGGER IF NOT EXISTS links_after_delete_tags AFTER DELETE ON links BEGIN
+    DELETE FROM link_tags WHERE link_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tags_after_delete AFTER DELETE ON tags BEGIN
+    DELETE FROM link_tags WHERE tag_id = old.id;
+END;
+
+-- Split the existing comma-separated tags into the new tables
+INSERT OR IGNORE INTO tags (user_id, name)
+WITH RECURSIVE split(link_id, user_id, tag, rest) AS (
+    SELECT id, user_id, '', tags || ',' FROM links
+    WHERE tags IS NOT NULL AND tags <> ''
+    UNION ALL
+    SELECT link_id, user_id,
+        LOWER(TRIM(substr(rest, 1, instr(rest, ',') - 1), ' #')),
+        substr(rest, instr(rest, ',') + 1)
+    FROM split
+    WHERE rest <> ''
+)
+SELECT DISTINCT user_id, tag FROM split WHERE tag <> '';
+
+INSERT OR IGNORE INTO link_tags (link_id, tag_id)
+WITH RECURSIVE split(link_id, user_id, tag, rest) AS (
+    SELECT id, user_id, '', tags || ',' FROM links
+    WHERE tags IS NOT NULL AND tags <> ''
+    UNION ALL
+    SELECT link_id, user_id,
+        LOWER(TRIM(substr(rest, 1, instr(rest, ',') - 1), ' #')),
+        substr(rest, instr(rest, ',') + 1)
+    FROM split
+    WHERE rest <> ''
+)
+SELECT split.link_id, tags.id FROM split
+JOIN tags ON tags.user_id = split.user_id AND tags.name = split.tag;
+
+-- Links whose tags did not contain any usable tag
+UPDATE links SET tags = NULL
+WHERE tags IS NOT NULL AND id NOT IN (SELECT link_id FROM link_tags);
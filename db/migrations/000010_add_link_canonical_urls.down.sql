@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_id_canonical_url_links;
+ALTER TABLE links DROP COLUMN canonical_url;
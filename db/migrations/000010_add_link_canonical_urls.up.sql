@@ -0,0 +1,6 @@
+-- Existing links are given a canonical_url by the server on startup, since
+-- normalizing URLs is not practical in SQL.
+ALTER TABLE links ADD COLUMN canonical_url TEXT;
+
+-- Create unique index on user_id and canonical_url in links table
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_id_canonical_url_links ON links(user_id, canonical_url);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_id_group_id_links;
+ALTER TABLE links DROP COLUMN group_id;
@@ -0,0 +1,4 @@
+ALTER TABLE links ADD COLUMN group_id TEXT;
+
+-- Create index on user_id and group_id in links table
+CREATE INDEX IF NOT EXISTS idx_user_id_group_id_links ON links(user_id, group_id);
@@ -0,0 +1,7 @@
+DELETE FROM links WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_user_id_deleted_at_links;
+DROP INDEX IF EXISTS idx_user_id_canonical_url_links;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_id_canonical_url_links ON links(user_id, canonical_url);
+
+ALTER TABLE links DROP COLUMN deleted_at;
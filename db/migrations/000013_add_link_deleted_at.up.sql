@@ -0,0 +1,8 @@
+ALTER TABLE links ADD COLUMN deleted_at DATETIME;
+
+-- Links in the trash don't count as duplicates of new links
+DROP INDEX IF EXISTS idx_user_id_canonical_url_links;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_id_canonical_url_links ON links(user_id, canonical_url) WHERE deleted_at IS NULL;
+
+-- Create index on user_id and deleted_at in links table for the trash
+CREATE INDEX IF NOT EXISTS idx_user_id_deleted_at_links ON links(user_id, deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS collections_after_delete;
+DROP TRIGGER IF EXISTS links_after_delete_collection_links;
+DROP INDEX IF EXISTS idx_collection_id_position_collection_links;
+DROP TABLE IF EXISTS collection_links;
+DROP INDEX IF EXISTS idx_user_id_parent_id_name_collections;
+DROP TABLE IF EXISTS collections;
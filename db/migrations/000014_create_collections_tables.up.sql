@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS collections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+-- Sibling collections have unique names. Top-level collections have a NULL
+-- parent_id, which a plain unique constraint would treat as distinct.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_id_parent_id_name_collections ON collections(user_id, COALESCE(parent_id, 0), name);
+
+-- A link is in at most one collection
+CREATE TABLE IF NOT EXISTS collection_links (
+    link_id INTEGER PRIMARY KEY,
+    collection_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE,
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE
+);
+
+-- Create index on collection_id and position in collection_links table
+CREATE INDEX IF NOT EXISTS idx_collection_id_position_collection_links ON collection_links(collection_id, position);
+
+-- Foreign keys are not enforced on our connections, so clean up the join
+-- table explicitly when either side is deleted.
+CREATE TRIGGER IF NOT EXISTS links_after_delete_collection_links AFTER DELETE ON links BEGIN
+    DELETE FROM collection_links WHERE link_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS collections_after_delete AFTER DELETE ON collections BEGIN
+    DELETE FROM collection_links WHERE collection_id = old.id;
+END;
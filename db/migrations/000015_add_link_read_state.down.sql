@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_id_unread_links;
+ALTER TABLE links DROP COLUMN archived_at;
+ALTER TABLE links DROP COLUMN read_at;
@@ -0,0 +1,5 @@
+ALTER TABLE links ADD COLUMN read_at DATETIME;
+ALTER TABLE links ADD COLUMN archived_at DATETIME;
+
+-- Keeps counting a user's unread links cheap
+CREATE INDEX IF NOT EXISTS idx_user_id_unread_links ON links(user_id) WHERE read_at IS NULL AND archived_at IS NULL AND deleted_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_id_pinned_position_links;
+ALTER TABLE links DROP COLUMN pinned_position;
+ALTER TABLE links DROP COLUMN starred;
@@ -0,0 +1,5 @@
+ALTER TABLE links ADD COLUMN starred BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN pinned_position INTEGER;
+
+-- Create index on user_id and pinned_position in links table for pinned links
+CREATE INDEX IF NOT EXISTS idx_user_id_pinned_position_links ON links(user_id, pinned_position) WHERE pinned_position IS NOT NULL;
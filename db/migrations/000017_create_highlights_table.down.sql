@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS links_after_delete_highlights;
+DROP INDEX IF EXISTS idx_link_id_highlights;
+DROP TABLE IF EXISTS highlights;
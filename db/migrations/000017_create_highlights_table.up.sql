@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS highlights (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    quote TEXT NOT NULL,
+    prefix TEXT,
+    suffix TEXT,
+    comment TEXT,
+    color TEXT NOT NULL DEFAULT 'yellow',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create index on link_id in highlights table
+CREATE INDEX IF NOT EXISTS idx_link_id_highlights ON highlights(link_id);
+
+-- Foreign keys are not enforced on our connections, so remove the highlights
+-- of links that are purged explicitly.
+CREATE TRIGGER IF NOT EXISTS links_after_delete_highlights AFTER DELETE ON links BEGIN
+    DELETE FROM highlights WHERE link_id = old.id;
+END;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_family_id_sessions;
+DROP TABLE IF EXISTS sessions;
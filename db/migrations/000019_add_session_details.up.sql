@@ -0,0 +1,6 @@
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+
+-- Create index on user_id in sessions table for the current refresh token of each session
+CREATE INDEX IF NOT EXISTS idx_user_id_active_sessions ON sessions(user_id) WHERE rotated_at IS NULL AND revoked_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_user_id_password_reset_tokens;
+DROP TABLE IF EXISTS password_reset_tokens;
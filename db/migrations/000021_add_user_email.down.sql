@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_email_users;
+ALTER TABLE users DROP COLUMN email_verification_nonce;
+ALTER TABLE users DROP COLUMN email_verified_at;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN email TEXT;
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+ALTER TABLE users ADD COLUMN email_verification_nonce TEXT;
+
+-- Create unique index on email in users table, ignoring case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_users ON users(email COLLATE NOCASE);
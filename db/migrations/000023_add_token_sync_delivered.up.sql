@@ -0,0 +1,2 @@
+ALTER TABLE tokens ADD COLUMN sync_delivered INTEGER NOT NULL DEFAULT 0;
+UPDATE tokens SET sync_delivered = sync_cursor;
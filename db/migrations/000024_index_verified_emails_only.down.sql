@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_email_users;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_users ON users(email COLLATE NOCASE);
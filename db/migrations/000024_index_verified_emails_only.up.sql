@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_email_users;
+
+-- Create unique index on verified emails in users table, ignoring case, so
+-- that unverified addresses can't lock out their owner
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_users ON users(email COLLATE NOCASE) WHERE email_verified_at IS NOT NULL;
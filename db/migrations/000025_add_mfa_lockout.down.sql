@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN mfa_locked_until;
+ALTER TABLE users DROP COLUMN mfa_failed_attempts;
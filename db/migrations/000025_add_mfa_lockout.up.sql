@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN mfa_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN mfa_locked_until DATETIME;
@@ -0,0 +1 @@
+ALTER TABLE links DROP COLUMN canonical_url_conflict;
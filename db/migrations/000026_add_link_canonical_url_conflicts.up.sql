@@ -0,0 +1,3 @@
+-- Links whose canonical URL is already taken by another link of the user keep
+-- a NULL canonical_url. They are marked so the startup backfill skips them.
+ALTER TABLE links ADD COLUMN canonical_url_conflict BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Set when sync consumers are done with a link, by acknowledging it or
+-- syncing past it. Links that are restored from the trash afterwards aren't
+-- claimed or pruned again.
+ALTER TABLE links ADD COLUMN consumed_at DATETIME;
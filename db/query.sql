@@ -1,12 +1,43 @@
 -- name: CreateUser :one
-INSERT INTO users (username, password)
-VALUES (?, ?)
+INSERT INTO users (username, password, email, email_verification_nonce)
+VALUES (?, ?, ?, ?)
 RETURNING id, username;
 
 -- name: GetUser :one
-SELECT id, username, password FROM users
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
 WHERE username = ?;
 
+-- name: GetUserByID :one
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
+WHERE id = ?;
+
+-- name: GetUserByEmail :one
+-- Only verified email addresses can be used to sign in.
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
+WHERE email = ? COLLATE NOCASE AND email_verified_at IS NOT NULL;
+
+-- name: SetUserEmail :exec
+UPDATE users
+SET email = ?, email_verified_at = NULL, email_verification_nonce = ?
+WHERE id = ?;
+
+-- name: SetEmailVerificationNonce :exec
+UPDATE users
+SET email_verification_nonce = ?
+WHERE id = ?;
+
+-- name: VerifyUserEmail :execrows
+-- Marks an email address as verified. The nonce is cleared, so each
+-- verification link works once and sending a new one invalidates the old.
+UPDATE users
+SET email_verified_at = ?, email_verification_nonce = NULL
+WHERE id = ? AND email = ? AND email_verification_nonce = ?;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = ?
+WHERE id = ?;
+
 -- name: CreateToken :exec
 INSERT INTO tokens (token_hash, name, short_token, user_id)
 VALUES (?, ?, ?, ?);
@@ -23,15 +54,674 @@ WHERE user_id = ?;
 DELETE FROM tokens
 WHERE id = ? AND user_id = ?;
 
+-- name: DeleteOtherTokens :exec
+-- Deletes every API token of a user except one. An ID of 0 deletes them all.
+DELETE FROM tokens
+WHERE user_id = ? AND id != ?;
+
 -- name: CreateLink :one
-INSERT INTO links (url, title, note, user_id, tags)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO links (url, title, note, user_id, domain, canonical_url, group_id)
+VALUES (?, ?, ?, ?, ?, ?, ?)
 RETURNING id, url;
 
 -- name: ListLinks :many
-SELECT url, title, note, bookmarked_at, tags FROM links
-WHERE user_id = ?;
+-- Pinned links come first.
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND deleted_at IS NULL
+ORDER BY pinned_position IS NULL, pinned_position, id;
+
+-- name: GetLink :one
+SELECT id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position FROM links
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
+
+-- name: GetLinkByCanonicalURL :one
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND canonical_url = ? AND deleted_at IS NULL;
+
+-- name: UpdateLink :one
+UPDATE links
+SET
+    url = COALESCE(sqlc.narg(url), url),
+    title = COALESCE(sqlc.narg(title), title),
+    note = COALESCE(sqlc.narg(note), note),
+    domain = COALESCE(sqlc.narg(domain), domain),
+    canonical_url = COALESCE(sqlc.narg(canonical_url), canonical_url)
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+RETURNING id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position;
+
+-- name: DeleteLink :execrows
+-- Moves a link to the trash. Trashed links are no longer pinned.
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
 
 -- name: ClearLinks :exec
+-- Moves all of a user's links to the trash.
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE user_id = ? AND deleted_at IS NULL;
+
+-- name: ClaimLinks :many
+UPDATE links
+SET lease_id = ?, lease_expires_at = ?
+WHERE id IN (
+    SELECT l.id FROM links l
+    WHERE l.user_id = ? AND l.deleted_at IS NULL AND l.consumed_at IS NULL AND (l.lease_expires_at IS NULL OR l.lease_expires_at < sqlc.arg(now))
+    ORDER BY l.id
+    LIMIT ?
+)
+RETURNING id, url, title, note, bookmarked_at, tags;
+
+-- name: AckLinks :execrows
+-- Moves acknowledged links to the trash, where they can still be restored.
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, consumed_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE user_id = ? AND lease_id = ? AND deleted_at IS NULL AND id IN (sqlc.slice('ids'));
+
+-- name: ListLinksSince :many
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND id > sqlc.arg(since) AND deleted_at IS NULL
+ORDER BY id
+LIMIT ?;
+
+-- name: AdvanceTokenCursor :exec
+-- The cursor doesn't move past the last link the token was sent, so that a
+-- cursor that is too far ahead can't prune links nobody received.
+UPDATE tokens
+SET sync_cursor = MIN(sqlc.arg(sync_cursor), sync_delivered), synced_at = ?
+WHERE id = ? AND user_id = ? AND sync_cursor <= MIN(sqlc.arg(sync_cursor), sync_delivered);
+
+-- name: MarkTokenDelivered :exec
+UPDATE tokens
+SET sync_delivered = MAX(sync_delivered, sqlc.arg(sync_delivered))
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id);
+
+-- name: PruneSyncedLinks :execrows
+-- Moves links the sync consumers are done with to the trash, where they can
+-- still be restored.
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, consumed_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE links.user_id = sqlc.arg(user_id) AND links.deleted_at IS NULL AND links.consumed_at IS NULL AND (
+    links.id <= (
+        SELECT COALESCE(MIN(t.sync_cursor), 0) FROM tokens t
+        WHERE t.user_id = sqlc.arg(user_id)
+    )
+    OR (
+        links.id <= (
+            SELECT COALESCE(MAX(t.sync_cursor), 0) FROM tokens t
+            WHERE t.user_id = sqlc.arg(user_id)
+        )
+        AND links.bookmarked_at < sqlc.arg(retention_cutoff)
+    )
+);
+
+-- name: ListLinksByFolder :many
+-- Orders links by their first tag so that each folder of an export, nested
+-- folders included, is contiguous. Slashes sort before any other character in
+-- folder_key for that reason.
+SELECT id, url, title, note, bookmarked_at, tags, folder_key FROM (
+    SELECT id, url, title, note, bookmarked_at, tags,
+        CAST(replace(substr(COALESCE(tags, ''), 1, instr(COALESCE(tags, '') || ',', ',') - 1), '/', char(1)) AS TEXT) AS folder_key
+    FROM links
+    WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+)
+WHERE folder_key > sqlc.arg(cursor_key)
+    OR (folder_key = sqlc.arg(cursor_key) AND id > sqlc.arg(cursor_id))
+ORDER BY folder_key, id
+LIMIT sqlc.arg(limit);
+
+-- name: ExportLinks :many
+-- Takes the same filters as ListLinksPage. Pages are read in id order, after
+-- cursor_id.
+SELECT id, url, canonical_url, title, note, tags, bookmarked_at FROM links
+WHERE user_id = sqlc.arg(user_id)
+    AND deleted_at IS NULL
+    AND (
+        CAST(sqlc.narg(tag) AS TEXT) IS NULL
+        OR EXISTS (
+            SELECT 1 FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = links.id AND (
+                t.name = LOWER(CAST(sqlc.narg(tag) AS TEXT))
+                OR substr(t.name, 1, length(CAST(sqlc.narg(tag) AS TEXT)) + 1) = LOWER(CAST(sqlc.narg(tag) AS TEXT)) || '/'
+            )
+        )
+    )
+    AND (
+        CAST(sqlc.narg(domain) AS TEXT) IS NULL
+        OR domain = CAST(sqlc.narg(domain) AS TEXT)
+        OR substr(domain, -length(CAST(sqlc.narg(domain) AS TEXT)) - 1) = '.' || CAST(sqlc.narg(domain) AS TEXT)
+    )
+    AND (CAST(sqlc.narg(before) AS INTEGER) IS NULL OR unixepoch(bookmarked_at) < CAST(sqlc.narg(before) AS INTEGER))
+    AND (CAST(sqlc.narg(after) AS INTEGER) IS NULL OR unixepoch(bookmarked_at) >= CAST(sqlc.narg(after) AS INTEGER))
+    AND (CAST(sqlc.narg(has_note) AS BOOLEAN) IS NULL OR (note IS NOT NULL AND note <> '') = CAST(sqlc.narg(has_note) AS BOOLEAN))
+    AND (CAST(sqlc.narg(group_id) AS TEXT) IS NULL OR group_id = CAST(sqlc.narg(group_id) AS TEXT))
+    AND (
+        CAST(sqlc.narg(state) AS TEXT) IS NULL
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'unread' AND read_at IS NULL AND archived_at IS NULL)
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'read' AND read_at IS NOT NULL AND archived_at IS NULL)
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'archived' AND archived_at IS NOT NULL)
+    )
+    AND (CAST(sqlc.narg(starred) AS BOOLEAN) IS NULL OR starred = CAST(sqlc.narg(starred) AS BOOLEAN))
+    AND id > sqlc.arg(cursor_id)
+ORDER BY id
+LIMIT sqlc.arg(limit);
+
+-- name: ListLinksPage :many
+SELECT id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position FROM links
+WHERE user_id = sqlc.arg(user_id)
+    AND deleted_at IS NULL
+    AND (
+        CAST(sqlc.narg(cursor_time) AS INTEGER) IS NULL
+        OR unixepoch(bookmarked_at) < CAST(sqlc.narg(cursor_time) AS INTEGER)
+        OR (unixepoch(bookmarked_at) = CAST(sqlc.narg(cursor_time) AS INTEGER) AND id < CAST(sqlc.narg(cursor_id) AS INTEGER))
+    )
+    AND (
+        CAST(sqlc.narg(tag) AS TEXT) IS NULL
+        OR EXISTS (
+            SELECT 1 FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = links.id AND (
+                t.name = LOWER(CAST(sqlc.narg(tag) AS TEXT))
+                OR substr(t.name, 1, length(CAST(sqlc.narg(tag) AS TEXT)) + 1) = LOWER(CAST(sqlc.narg(tag) AS TEXT)) || '/'
+            )
+        )
+    )
+    AND (
+        CAST(sqlc.narg(domain) AS TEXT) IS NULL
+        OR domain = CAST(sqlc.narg(domain) AS TEXT)
+        OR substr(domain, -length(CAST(sqlc.narg(domain) AS TEXT)) - 1) = '.' || CAST(sqlc.narg(domain) AS TEXT)
+    )
+    AND (CAST(sqlc.narg(before) AS INTEGER) IS NULL OR unixepoch(bookmarked_at) < CAST(sqlc.narg(before) AS INTEGER))
+    AND (CAST(sqlc.narg(after) AS INTEGER) IS NULL OR unixepoch(bookmarked_at) >= CAST(sqlc.narg(after) AS INTEGER))
+    AND (CAST(sqlc.narg(has_note) AS BOOLEAN) IS NULL OR (note IS NOT NULL AND note <> '') = CAST(sqlc.narg(has_note) AS BOOLEAN))
+    AND (CAST(sqlc.narg(group_id) AS TEXT) IS NULL OR group_id = CAST(sqlc.narg(group_id) AS TEXT))
+    AND (
+        CAST(sqlc.narg(state) AS TEXT) IS NULL
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'unread' AND read_at IS NULL AND archived_at IS NULL)
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'read' AND read_at IS NOT NULL AND archived_at IS NULL)
+        OR (CAST(sqlc.narg(state) AS TEXT) = 'archived' AND archived_at IS NOT NULL)
+    )
+    AND (CAST(sqlc.narg(starred) AS BOOLEAN) IS NULL OR starred = CAST(sqlc.narg(starred) AS BOOLEAN))
+    AND (CAST(sqlc.narg(pinned) AS BOOLEAN) IS NULL OR (pinned_position IS NOT NULL) = CAST(sqlc.narg(pinned) AS BOOLEAN))
+ORDER BY unixepoch(bookmarked_at) DESC, id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: SearchLinks :many
+SELECT
+    l.id, l.url, l.title, l.note, l.bookmarked_at, l.tags,
+    CAST(COALESCE(snippet(links_fts, 0, '<mark>', '</mark>', '…', 12), '') AS TEXT) AS title_snippet,
+    CAST(COALESCE(snippet(links_fts, 1, '<mark>', '</mark>', '…', 24), '') AS TEXT) AS note_snippet,
+    CAST(bm25(links_fts, 10.0, 4.0, 2.0, 6.0) AS REAL) AS rank
+FROM links_fts
+JOIN links l ON l.id = links_fts.rowid
+WHERE links_fts MATCH CAST(sqlc.arg(query) AS TEXT) AND l.user_id = sqlc.arg(user_id) AND l.deleted_at IS NULL
+ORDER BY rank
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: UpsertTag :one
+-- The no-op update makes RETURNING yield the id of an existing tag.
+INSERT INTO tags (user_id, name)
+VALUES (?, ?)
+ON CONFLICT (user_id, name) DO UPDATE SET user_id = excluded.user_id
+RETURNING id;
+
+-- name: AddLinkTag :exec
+INSERT OR IGNORE INTO link_tags (link_id, tag_id)
+VALUES (?, ?);
+
+-- name: ClearLinkTags :exec
+DELETE FROM link_tags
+WHERE link_id = ?;
+
+-- name: ListTagsWithCounts :many
+SELECT
+    t.id,
+    t.name,
+    COUNT(l.id) AS link_count,
+    CAST(MAX(unixepoch(l.bookmarked_at)) AS INTEGER) AS last_used_at
+FROM tags t
+LEFT JOIN link_tags lt ON lt.tag_id = t.id
+LEFT JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+WHERE t.user_id = ?
+GROUP BY t.id
+ORDER BY t.name;
+
+-- name: GetTag :one
+SELECT id, name FROM tags
+WHERE id = ? AND user_id = ?;
+
+-- name: GetTagByName :one
+SELECT id, name FROM tags
+WHERE user_id = ? AND name = ?;
+
+-- name: RenameTag :exec
+UPDATE tags
+SET name = ?
+WHERE id = ? AND user_id = ?;
+
+-- name: MoveLinkTags :exec
+INSERT OR IGNORE INTO link_tags (link_id, tag_id)
+SELECT link_id, sqlc.arg(target_tag_id) FROM link_tags
+WHERE tag_id = sqlc.arg(source_tag_id);
+
+-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE id = ? AND user_id = ?;
+
+-- name: ListTagDescendants :many
+SELECT id, name FROM tags
+WHERE user_id = sqlc.arg(user_id) AND (
+    name = sqlc.arg(name) OR substr(name, 1, length(sqlc.arg(name)) + 1) = sqlc.arg(name) || '/'
+)
+ORDER BY name;
+
+-- name: ListTagLinks :many
+SELECT t.name, lt.link_id FROM tags t
+JOIN link_tags lt ON lt.tag_id = t.id
+JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+WHERE t.user_id = ?;
+
+-- name: ListLinksWithoutCanonicalURL :many
+SELECT id, url FROM links
+WHERE canonical_url IS NULL AND NOT canonical_url_conflict
+ORDER BY id;
+
+-- name: SetLinkBookmarkedAt :exec
+UPDATE links
+SET bookmarked_at = ?
+WHERE id = ? AND user_id = ?;
+
+-- name: SetLinkCanonicalURL :execrows
+-- Duplicates of a link that already has the canonical URL are left without one.
+UPDATE OR IGNORE links
+SET canonical_url = ?
+WHERE id = ?;
+
+-- name: MarkLinkCanonicalURLConflict :exec
+UPDATE links
+SET canonical_url_conflict = 1
+WHERE id = ?;
+
+-- name: CreateTemplate :one
+INSERT INTO templates (user_id, name, body, per_link)
+VALUES (?, ?, ?, ?)
+RETURNING id, name, body, per_link, created_at, updated_at;
+
+-- name: ListTemplates :many
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE user_id = ?
+ORDER BY name;
+
+-- name: GetTemplate :one
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE id = ? AND user_id = ?;
+
+-- name: GetTemplateByName :one
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE user_id = ? AND name = ?;
+
+-- name: UpdateTemplate :one
+UPDATE templates
+SET
+    name = COALESCE(sqlc.narg(name), name),
+    body = COALESCE(sqlc.narg(body), body),
+    per_link = COALESCE(sqlc.narg(per_link), per_link),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+RETURNING id, name, body, per_link, created_at, updated_at;
+
+-- name: DeleteTemplate :execrows
+DELETE FROM templates
+WHERE id = ? AND user_id = ?;
+
+-- name: ListTrash :many
+SELECT id, url, title, note, bookmarked_at, tags, deleted_at FROM links
+WHERE user_id = ? AND deleted_at IS NOT NULL
+ORDER BY unixepoch(deleted_at) DESC, id DESC
+LIMIT ? OFFSET ?;
+
+-- name: RestoreLink :execrows
+-- Restored links keep consumed_at, so that sync consumers that were done with
+-- them don't claim or prune them again.
+UPDATE links
+SET deleted_at = NULL, lease_id = NULL, lease_expires_at = NULL
+WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL;
+
+-- name: PurgeLink :execrows
+DELETE FROM links
+WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL;
+
+-- name: EmptyTrash :execrows
 DELETE FROM links
+WHERE user_id = ? AND deleted_at IS NOT NULL;
+
+-- name: PurgeDeletedLinks :execrows
+-- Permanently deletes the links of all users that have been in the trash
+-- since before the cutoff.
+DELETE FROM links
+WHERE deleted_at IS NOT NULL AND unixepoch(deleted_at) < CAST(sqlc.arg(cutoff) AS INTEGER);
+
+-- name: CreateCollection :one
+INSERT INTO collections (user_id, parent_id, name)
+VALUES (?, ?, ?)
+RETURNING id, parent_id, name, created_at;
+
+-- name: GetCollection :one
+SELECT id, parent_id, name, created_at FROM collections
+WHERE id = ? AND user_id = ?;
+
+-- name: ListCollections :many
+SELECT
+    c.id,
+    c.parent_id,
+    c.name,
+    c.created_at,
+    COUNT(l.id) AS link_count
+FROM collections c
+LEFT JOIN collection_links cl ON cl.collection_id = c.id
+LEFT JOIN links l ON l.id = cl.link_id AND l.deleted_at IS NULL
+WHERE c.user_id = ?
+GROUP BY c.id
+ORDER BY c.name;
+
+-- name: UpdateCollection :one
+UPDATE collections
+SET name = ?, parent_id = ?
+WHERE id = ? AND user_id = ?
+RETURNING id, parent_id, name, created_at;
+
+-- name: DeleteCollection :execrows
+-- Deletes a collection along with all collections nested in it.
+DELETE FROM collections
+WHERE id IN (
+    WITH RECURSIVE tree(id) AS (
+        SELECT c.id FROM collections c
+        WHERE c.id = sqlc.arg(id) AND c.user_id = sqlc.arg(user_id)
+        UNION ALL
+        SELECT c.id FROM collections c
+        JOIN tree ON c.parent_id = tree.id
+    )
+    SELECT id FROM tree
+);
+
+-- name: ListCollectionLinks :many
+SELECT l.id, l.url, l.title, l.note, l.bookmarked_at, l.tags, cl.position FROM collection_links cl
+JOIN links l ON l.id = cl.link_id
+WHERE cl.collection_id = ? AND l.user_id = ? AND l.deleted_at IS NULL
+ORDER BY cl.position, l.id;
+
+-- name: GetCollectionLink :one
+SELECT collection_id, position FROM collection_links
+WHERE link_id = ?;
+
+-- name: CountCollectionLinks :one
+SELECT COUNT(*) FROM collection_links
+WHERE collection_id = ?;
+
+-- name: AddCollectionLink :exec
+INSERT INTO collection_links (link_id, collection_id, position)
+VALUES (?, ?, ?);
+
+-- name: RemoveCollectionLink :exec
+DELETE FROM collection_links
+WHERE link_id = ?;
+
+-- name: ShiftCollectionLinks :exec
+-- Moves the links at or after a position in a collection up or down by delta.
+UPDATE collection_links
+SET position = position + sqlc.arg(delta)
+WHERE collection_id = sqlc.arg(collection_id) AND position >= sqlc.arg(from_position);
+
+-- name: MarkLinksRead :execrows
+-- Links that were already read keep their read_at. Archived links are moved
+-- back to the reading list.
+UPDATE links
+SET read_at = COALESCE(read_at, sqlc.arg(read_at)), archived_at = NULL
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL AND id IN (sqlc.slice('ids'));
+
+-- name: MarkLinksUnread :execrows
+UPDATE links
+SET read_at = NULL, archived_at = NULL
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL AND id IN (sqlc.slice('ids'));
+
+-- name: MarkLinksArchived :execrows
+UPDATE links
+SET archived_at = COALESCE(archived_at, sqlc.arg(archived_at))
+WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL AND id IN (sqlc.slice('ids'));
+
+-- name: GetLinkStats :one
+-- Unread links are links that are neither read nor archived. Read links don't
+-- include archived links.
+SELECT
+    COUNT(*) AS total,
+    CAST(COALESCE(SUM(read_at IS NULL AND archived_at IS NULL), 0) AS INTEGER) AS unread,
+    CAST(COALESCE(SUM(read_at IS NOT NULL AND archived_at IS NULL), 0) AS INTEGER) AS read,
+    CAST(COALESCE(SUM(archived_at IS NOT NULL), 0) AS INTEGER) AS archived
+FROM links
+WHERE user_id = ? AND deleted_at IS NULL;
+
+-- name: SetLinkStarred :execrows
+UPDATE links
+SET starred = ?
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
+
+-- name: GetPinnedLinkStats :one
+SELECT
+    COUNT(*) AS count,
+    CAST(COALESCE(MAX(pinned_position) + 1, 0) AS INTEGER) AS next_position
+FROM links
+WHERE user_id = ? AND pinned_position IS NOT NULL AND deleted_at IS NULL;
+
+-- name: SetLinkPinnedPosition :execrows
+-- A NULL position unpins the link.
+UPDATE links
+SET pinned_position = ?
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL;
+
+-- name: ShiftPinnedLinks :exec
+-- Moves the pinned links at or after a position up or down by delta.
+UPDATE links
+SET pinned_position = pinned_position + sqlc.arg(delta)
+WHERE user_id = sqlc.arg(user_id) AND pinned_position >= sqlc.arg(from_position);
+
+-- name: CompactPinnedLinks :exec
+-- Numbers a user's pinned links from 0 again, closing the gaps left by links
+-- that were unpinned together.
+UPDATE links
+SET pinned_position = pinned.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY pinned_position, id) - 1 AS position
+    FROM links
+    WHERE user_id = sqlc.arg(user_id) AND pinned_position IS NOT NULL
+) AS pinned
+WHERE links.id = pinned.id AND links.pinned_position <> pinned.position;
+
+-- name: CreateHighlight :one
+INSERT INTO highlights (link_id, user_id, quote, prefix, suffix, comment, color)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at;
+
+-- name: ListHighlights :many
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE link_id = ? AND user_id = ?
+ORDER BY id;
+
+-- name: ListHighlightsByLinkIDs :many
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE user_id = ? AND link_id IN (sqlc.slice('link_ids'))
+ORDER BY link_id, id;
+
+-- name: GetHighlight :one
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE id = ? AND link_id = ? AND user_id = ?;
+
+-- name: UpdateHighlight :one
+UPDATE highlights
+SET
+    quote = COALESCE(sqlc.narg(quote), quote),
+    prefix = COALESCE(sqlc.narg(prefix), prefix),
+    suffix = COALESCE(sqlc.narg(suffix), suffix),
+    comment = COALESCE(sqlc.narg(comment), comment),
+    color = COALESCE(sqlc.narg(color), color),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND link_id = sqlc.arg(link_id) AND user_id = sqlc.arg(user_id)
+RETURNING id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at;
+
+-- name: DeleteHighlight :execrows
+DELETE FROM highlights
+WHERE id = ? AND link_id = ? AND user_id = ?;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (user_id, family_id, refresh_token_hash, expires_at, user_agent, ip_address, last_seen_at)
+VALUES (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetSessionByRefreshTokenHash :one
+SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM sessions
+WHERE refresh_token_hash = ?;
+
+-- name: RotateSession :execrows
+-- Marks a refresh token as exchanged. Zero rows are affected if it already
+-- was, which means the token is being reused.
+UPDATE sessions
+SET rotated_at = ?
+WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeSessionFamily :execrows
+UPDATE sessions
+SET revoked_at = ?
+WHERE family_id = ? AND user_id = ? AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+-- Revokes every session of a user except one. An empty family ID revokes
+-- them all.
+UPDATE sessions
+SET revoked_at = ?
+WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL;
+
+-- name: GetActiveSession :one
+-- Gets the current refresh token of a session. There is none once the
+-- session is revoked.
+SELECT id, user_id, last_seen_at FROM sessions
+WHERE family_id = ? AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?, user_agent = ?, ip_address = ?
+WHERE id = ?;
+
+-- name: ListSessions :many
+SELECT family_id, user_agent, ip_address, last_seen_at, expires_at FROM sessions
+WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND unixepoch(expires_at) > CAST(sqlc.arg(now) AS INTEGER)
+ORDER BY unixepoch(last_seen_at) DESC, id DESC;
+
+-- name: PurgeExpiredSessions :execrows
+DELETE FROM sessions
+WHERE unixepoch(expires_at) < CAST(sqlc.arg(cutoff) AS INTEGER);
+
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetPasswordResetToken :one
+SELECT id, user_id, expires_at, used_at FROM password_reset_tokens
+WHERE token_hash = ?;
+
+-- name: UsePasswordResetToken :execrows
+UPDATE password_reset_tokens
+SET used_at = ?
+WHERE id = ? AND used_at IS NULL;
+
+-- name: DeletePasswordResetToken :exec
+DELETE FROM password_reset_tokens
+WHERE token_hash = ?;
+
+-- name: InvalidatePasswordResetTokens :execrows
+-- Uses up every outstanding reset token of a user, so that only one of them
+-- can ever reset the password.
+UPDATE password_reset_tokens
+SET used_at = ?
+WHERE user_id = ? AND used_at IS NULL;
+
+-- name: PurgeExpiredPasswordResetTokens :execrows
+DELETE FROM password_reset_tokens
+WHERE unixepoch(expires_at) < CAST(sqlc.arg(cutoff) AS INTEGER);
+
+-- name: GetUserTOTP :one
+SELECT totp_secret, totp_enabled_at, mfa_locked_until FROM users
+WHERE id = ?;
+
+-- name: SetUserTOTPSecret :exec
+-- Starts enrolling a new TOTP secret, or removes it with a NULL secret. Either
+-- way two-factor authentication is off until a code is confirmed.
+UPDATE users
+SET totp_secret = ?, totp_enabled_at = NULL, totp_last_used_step = NULL, mfa_failed_attempts = 0, mfa_locked_until = NULL
+WHERE id = ?;
+
+-- name: EnableUserTOTP :execrows
+UPDATE users
+SET totp_enabled_at = ?
+WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+-- Records the period of a TOTP code that was just used. Zero rows are
+-- affected if a code of that period, or a later one, was used already.
+UPDATE users
+SET totp_last_used_step = CAST(sqlc.arg(step) AS INTEGER)
+WHERE id = sqlc.arg(id) AND (totp_last_used_step IS NULL OR totp_last_used_step < CAST(sqlc.arg(step) AS INTEGER));
+
+-- name: RecordMFAFailure :one
+-- Counts a wrong second factor code, and returns how many there were in a
+-- row.
+UPDATE users
+SET mfa_failed_attempts = mfa_failed_attempts + 1
+WHERE id = ?
+RETURNING mfa_failed_attempts;
+
+-- name: LockMFA :exec
+UPDATE users
+SET mfa_locked_until = ?
+WHERE id = ?;
+
+-- name: ResetMFAFailures :exec
+UPDATE users
+SET mfa_failed_attempts = 0, mfa_locked_until = NULL
+WHERE id = ? AND (mfa_failed_attempts > 0 OR mfa_locked_until IS NOT NULL);
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES (?, ?);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
 WHERE user_id = ?;
+
+-- name: CountRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = ? AND used_at IS NULL;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = ?
+WHERE user_id = ? AND code_hash = ? AND used_at IS NULL;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+VALUES (?, ?, ?);
+
+-- name: GetMFAChallenge :one
+SELECT id, user_id, attempts, expires_at FROM mfa_challenges
+WHERE token_hash = ?;
+
+-- name: IncrementMFAChallengeAttempts :exec
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = ?;
+
+-- name: DeleteMFAChallenge :execrows
+DELETE FROM mfa_challenges
+WHERE id = ?;
+
+-- name: PurgeExpiredMFAChallenges :execrows
+DELETE FROM mfa_challenges
+WHERE unixepoch(expires_at) < CAST(sqlc.arg(cutoff) AS INTEGER);
@@ -47,8 +47,13 @@ var DefaultParams = &params{
 var (
 	ErrInvalidHash         = errors.New("the encoded hash is not in the correct format")
 	ErrIncompatibleVersion = errors.New("incompatible version of argon2")
+	ErrSessionRevoked      = errors.New("session has been revoked")
 )
 
+// sessionTouchInterval is how often the last-seen time of a session is
+// updated, so that not every request writes to the database.
+const sessionTouchInterval = time.Minute
+
 func HashPassword(password string, p *params) (string, error) {
 	salt, err := generateRandomBytes(p.saltLength)
 	if err != nil {
@@ -87,12 +92,15 @@ func ComparePasswordAndHash(password, encodedHash string) (bool, error) {
 	return false, nil
 }
 
-func GenerateJWT(userID int64, username string) (string, error) {
+// GenerateJWT signs an access token for a session. The session ID is the
+// token's jti, which GetMiddleware checks to tell if the session was revoked.
+func GenerateJWT(userID int64, username string, sessionID string) (string, error) {
 	claims := &JWTCustomClaims{
 		username,
 		jwt.RegisteredClaims{
+			ID:        sessionID,
 			Subject:   fmt.Sprintf("%d", userID),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 1 day expiration
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenDuration)),
 		},
 	}
 
@@ -118,6 +126,11 @@ func DecodeJWT(tokenString string) (*JWTCustomClaims, error) {
 		return nil, errors.New("invalid token")
 	}
 
+	// Tokens for other purposes, such as email verification, have an audience
+	if len(claims.Audience) != 0 {
+		return nil, errors.New("invalid token")
+	}
+
 	return claims, nil
 }
 
@@ -154,9 +167,21 @@ func GetMiddleware(repository *repository.Queries) echo.MiddlewareFunc {
 					return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: "+err.Error())
 				}
 
+				err = CheckSession(c, repository, claims)
+				if err != nil {
+					if err == ErrSessionRevoked {
+						return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: "+err.Error())
+					}
+					return err
+				}
+
 				fmt.Printf("Authenticated user: %s (ID: %s)\n", claims.Username, claims.Subject)
 
 				c.Set("userID", claims.Subject)
+				// Tokens issued before sessions existed have no session
+				if claims.ID != "" {
+					c.Set("sessionID", claims.ID)
+				}
 			} else {
 				// Handle X-Api-Token authentication
 				key, err := apikey.ParseAPIKey(tokenHeader)
@@ -173,6 +198,7 @@ func GetMiddleware(repository *repository.Queries) echo.MiddlewareFunc {
 				}
 
 				c.Set("userID", strconv.FormatInt(row.UserID, 10))
+				c.Set("tokenID", row.ID)
 			}
 
 			return next(c)
@@ -180,6 +206,39 @@ func GetMiddleware(repository *repository.Queries) echo.MiddlewareFunc {
 	}
 }
 
+// CheckSession makes sure the session a JWT was issued for hasn't been
+// revoked, and records when and from where it was last used.
+func CheckSession(c echo.Context, q *repository.Queries, claims *JWTCustomClaims) error {
+	// Tokens issued before sessions existed have no session to check. They
+	// are accepted until they expire, a day at most, rather than signing
+	// everyone out on upgrade.
+	if claims.ID == "" {
+		return nil
+	}
+
+	ctx := c.Request().Context()
+
+	session, err := q.GetActiveSession(ctx, claims.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if session.LastSeenAt.Valid && now.Sub(session.LastSeenAt.Time) < sessionTouchInterval {
+		return nil
+	}
+
+	return q.TouchSession(ctx, repository.TouchSessionParams{
+		LastSeenAt: sql.NullTime{Time: now, Valid: true},
+		UserAgent:  sql.NullString{String: c.Request().UserAgent(), Valid: true},
+		IpAddress:  sql.NullString{String: c.RealIP(), Valid: true},
+		ID:         session.ID,
+	})
+}
+
 func generateRandomBytes(n uint32) ([]byte, error) {
 	b := make([]byte, n)
 	_, err := rand.Read(b)
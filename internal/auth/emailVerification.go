@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// emailVerificationAudience marks JWTs that verify an email address, so that
+// they aren't accepted as access tokens.
+const emailVerificationAudience = "email_verification"
+
+type EmailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// GenerateEmailVerificationToken signs the token of an email verification
+// link. Its ID is the nonce stored with the user's email address, which
+// verifying it clears.
+func GenerateEmailVerificationToken(userID int64, email string, nonce string, expiresAt time.Time) (string, error) {
+	claims := &EmailVerificationClaims{
+		email,
+		jwt.RegisteredClaims{
+			ID:        nonce,
+			Subject:   fmt.Sprintf("%d", userID),
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+
+	return token.SignedString([]byte(GetJWTEncodingSecret()))
+}
+
+func DecodeEmailVerificationToken(tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(GetJWTEncodingSecret()), nil
+	}, jwt.WithAudience(emailVerificationAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"strings"
+)
+
+// recoveryCodeGroup is how many characters of a recovery code are shown
+// between dashes.
+const recoveryCodeGroup = 4
+
+// NewRecoveryCode generates a one-time code that stands in for a TOTP code
+// when the user's authenticator is lost, such as "abcd-efgh-ijkl-mnop".
+func NewRecoveryCode() (string, error) {
+	b, err := generateRandomBytes(10)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(totpEncoding.EncodeToString(b))
+
+	var groups []string
+	for len(code) > 0 {
+		n := min(recoveryCodeGroup, len(code))
+		groups = append(groups, code[:n])
+		code = code[n:]
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage. Dashes, spaces and
+// case don't matter.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	return HashSecretToken(code)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// AccessTokenDuration is how long a JWT issued at sign-in or refresh is valid.
+// Clients exchange their refresh token for a new one when it runs out.
+const AccessTokenDuration = 15 * time.Minute
+
+// NewSecretToken generates a random token, such as a refresh token or a
+// password reset token, and the hash it is stored under.
+func NewSecretToken() (token string, hash string, err error) {
+	b, err := generateRandomBytes(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashSecretToken(token), nil
+}
+
+// HashSecretToken hashes a token from NewSecretToken for lookup. The tokens
+// are random, so a fast hash is enough.
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// NewRandomID generates a random ID, such as the ID shared by a refresh token
+// and all the tokens it is rotated into.
+func NewRandomID() (string, error) {
+	b, err := generateRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// The last six digits of the eight digit codes in RFC 6238 appendix B
+	tests := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, expected := range tests {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if code != expected {
+			t.Errorf("TOTPCode(%d) = %q, expected %q", unix, code, expected)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	previous, _ := TOTPCode(rfc6238Secret, now.Add(-30*time.Second))
+	if step, ok := ValidateTOTP(rfc6238Secret, previous, now); !ok || step != now.Unix()/30-1 {
+		t.Errorf("ValidateTOTP(previous code) = %d, %v", step, ok)
+	}
+
+	tooOld, _ := TOTPCode(rfc6238Secret, now.Add(-90*time.Second))
+	if _, ok := ValidateTOTP(rfc6238Secret, tooOld, now); ok {
+		t.Error("ValidateTOTP() accepted a code from three periods ago")
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("ValidateTOTP(%q) succeeded", code)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("LinkStowr", "alice smith", "SECRET")
+
+	expected := "otpauth://totp/LinkStowr:alice%20smith?"
+	if !strings.HasPrefix(uri, expected) || !strings.Contains(uri, "secret=SECRET") || !strings.Contains(uri, "issuer=LinkStowr") {
+		t.Errorf("TOTPURI() = %q", uri)
+	}
+}
@@ -0,0 +1,24 @@
+package canonicalurl
+
+import "testing"
+
+func TestCanonicalize(t *testing.T) {
+	tests := map[string]string{
+		"HTTPS://Example.COM":                                "https://example.com/",
+		"https://example.com:443/Path#section":               "https://example.com/Path",
+		"http://example.com:80/a":                            "http://example.com/a",
+		"http://example.com:8080/a":                          "http://example.com:8080/a",
+		"https://example.com/a?b=2&a=1":                      "https://example.com/a?a=1&b=2",
+		"https://example.com/a?utm_source=x&id=5&fbclid=abc": "https://example.com/a?id=5",
+		"https://example.com/a?UTM_Medium=email":             "https://example.com/a",
+		"https://[::1]:443/":                                 "https://[::1]/",
+		"  https://example.com/a?  ":                         "https://example.com/a",
+		"not a url":                                          "not a url",
+	}
+
+	for input, expected := range tests {
+		if actual := Canonicalize(input); actual != expected {
+			t.Errorf("Canonicalize(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
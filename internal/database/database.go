@@ -3,10 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -47,7 +50,7 @@ func New() Service {
 		return dbInstance
 	}
 
-	db, err := sql.Open("sqlite3", dburl)
+	db, err := sql.Open("sqlite3", DSN(dburl))
 	if err != nil {
 		// This will not be a connection error, but a DSN parse error or
 		// another initialization error.
@@ -60,6 +63,30 @@ func New() Service {
 	return dbInstance
 }
 
+// busyTimeout is how long a connection waits for another connection's lock
+// before failing with "database is locked".
+const busyTimeout = 5 * time.Second
+
+// DSN adds the connection options the server relies on to the data source
+// name of a database. Foreign keys are enforced, since the schema relies on
+// them to delete the rows that belong to a deleted row. Transactions take the
+// write lock when they begin, so that two transactions that read and then
+// write can't deadlock on upgrading their locks, and wait for it rather than
+// failing right away.
+func DSN(name string) string {
+	options := url.Values{}
+	options.Set("_foreign_keys", "on")
+	options.Set("_txlock", "immediate")
+	options.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+
+	separator := "?"
+	if strings.Contains(name, "?") {
+		separator = "&"
+	}
+
+	return name + separator + options.Encode()
+}
+
 func (s *service) GetDB() *sql.DB {
 	if s.db == nil {
 		log.Fatal("Database connection is not initialized")
@@ -68,6 +95,10 @@ func (s *service) GetDB() *sql.DB {
 }
 
 func (s *service) RunMigrations() error {
+	if err := checkFTS5(s.db); err != nil {
+		return err
+	}
+
 	driver, err := sqlite3.WithInstance(s.db, &sqlite3.Config{})
 	if err != nil {
 		return err
@@ -87,6 +118,21 @@ func (s *service) RunMigrations() error {
 	return nil
 }
 
+// checkFTS5 makes sure SQLite was built with FTS5, which link search and its
+// migration need. go-sqlite3 only includes it with the sqlite_fts5 build tag.
+func checkFTS5(db *sql.DB) error {
+	var enabled bool
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return err
+	}
+
+	if !enabled {
+		return errors.New("SQLite was built without FTS5, which link search needs: build with -tags sqlite_fts5, as make build does")
+	}
+
+	return nil
+}
+
 // Health checks the health of the database connection by pinging the database.
 // It returns a map with keys indicating various health statistics.
 func (s *service) Health() map[string]string {
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// TestFTS5 fails the tests run without the sqlite_fts5 build tag, which skip
+// every test that needs a migrated database.
+func TestFTS5(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := checkFTS5(db); err != nil {
+		t.Fatalf("%v. Without it, the server tests that need a database don't run: use make test or go test -tags sqlite_fts5 ./...", err)
+	}
+}
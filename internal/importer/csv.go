@@ -0,0 +1,67 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+)
+
+// csvRow is a row of a CSV export, addressed by column name.
+type csvRow struct {
+	columns map[string]int
+	fields  []string
+}
+
+// get returns the value of a column, or "" if the export doesn't have it.
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+
+	return strings.TrimSpace(r.fields[i])
+}
+
+// readCSV calls fn for each row of a CSV file whose first row names the
+// columns. Column names are matched case-insensitively. It fails if any of
+// the required columns is missing.
+func readCSV(r io.Reader, required []string, fn func(row csvRow)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("empty CSV file")
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, column := range required {
+		if _, ok := columns[column]; !ok {
+			return errors.New("missing CSV column " + column)
+		}
+	}
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		fn(csvRow{columns: columns, fields: fields})
+	}
+}
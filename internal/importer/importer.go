@@ -0,0 +1,62 @@
+// Package importer reads the export files of other bookmarking and
+// read-it-later services into a common record format.
+package importer
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Record is a link read from an export file.
+type Record struct {
+	URL   string
+	Title string
+	Note  string
+	Tags  []string
+	// Timestamp is when the link was saved, or zero if the export doesn't
+	// say.
+	Timestamp time.Time
+	Read      bool
+}
+
+// Parser reads all records from an export file.
+type Parser func(r io.Reader) ([]Record, error)
+
+var parsers = map[string]Parser{
+	"netscape":   ParseNetscape,
+	"pocket":     ParsePocket,
+	"instapaper": ParseInstapaper,
+	"raindrop":   ParseRaindrop,
+}
+
+// Lookup returns the parser for a format name.
+func Lookup(format string) (Parser, bool) {
+	parser, ok := parsers[strings.ToLower(format)]
+	return parser, ok
+}
+
+// splitList splits a separated list of values, dropping empty ones.
+func splitList(value, sep string) []string {
+	var values []string
+	for _, v := range strings.Split(value, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// folderTag turns a folder path into a nested tag. Slashes and commas inside
+// folder names would split the tag, so they are replaced.
+func folderTag(folders []string) string {
+	replacer := strings.NewReplacer("/", " ", ",", " ")
+
+	segments := make([]string, len(folders))
+	for i, folder := range folders {
+		segments[i] = replacer.Replace(folder)
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		format   string
+		input    string
+		expected []Record
+	}{
+		{
+			format: "pocket",
+			input: `<!DOCTYPE html>
+<html>
+	<head><title>Pocket Export</title></head>
+	<body>
+		<h1>Unread</h1>
+		<ul>
+			<li><a href="https://go.dev/" time_added="1700000000" tags="dev,go">The Go Programming Language</a></li>
+		</ul>
+
+		<h1>Read Archive</h1>
+		<ul>
+			<li><a href="https://sqlite.org/" time_added="1600000000" tags="">SQLite</a></li>
+		</ul>
+	</body>
+</html>`,
+			expected: []Record{
+				{
+					URL:       "https://go.dev/",
+					Title:     "The Go Programming Language",
+					Tags:      []string{"dev", "go"},
+					Timestamp: time.Unix(1700000000, 0).UTC(),
+				},
+				{
+					URL:       "https://sqlite.org/",
+					Title:     "SQLite",
+					Timestamp: time.Unix(1600000000, 0).UTC(),
+					Read:      true,
+				},
+			},
+		},
+		{
+			format: "instapaper",
+			input: "\ufeffURL,Title,Selection,Folder,Timestamp,Tags\n" +
+				"https://go.dev/,Go,\"A language, for sure\",Unread,1700000000,\"[\"\"dev\"\"]\"\n" +
+				"https://sqlite.org/,SQLite,,Archive,1600000000,[]\n" +
+				"https://example.com/,Example,,Reading/Later,,\n",
+			expected: []Record{
+				{
+					URL:       "https://go.dev/",
+					Title:     "Go",
+					Note:      "A language, for sure",
+					Tags:      []string{"dev"},
+					Timestamp: time.Unix(1700000000, 0).UTC(),
+				},
+				{
+					URL:       "https://sqlite.org/",
+					Title:     "SQLite",
+					Tags:      []string{},
+					Timestamp: time.Unix(1600000000, 0).UTC(),
+					Read:      true,
+				},
+				{
+					URL:   "https://example.com/",
+					Title: "Example",
+					Tags:  []string{"Reading Later"},
+				},
+			},
+		},
+		{
+			format: "raindrop",
+			input: "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n" +
+				"1,Go,My note,An excerpt,https://go.dev/,Dev / Languages,\"go, lang\",2023-11-14T22:13:20.000Z,,,false\n" +
+				"2,SQLite,,,https://sqlite.org/,Unsorted,,,,,false\n",
+			expected: []Record{
+				{
+					URL:       "https://go.dev/",
+					Title:     "Go",
+					Note:      "My note",
+					Tags:      []string{"go", "lang", "Dev/Languages"},
+					Timestamp: time.Unix(1700000000, 0).UTC(),
+				},
+				{
+					URL:   "https://sqlite.org/",
+					Title: "SQLite",
+				},
+			},
+		},
+		{
+			format: "netscape",
+			input: `<DL><p>
+	<DT><H3>Dev</H3>
+	<DL><p>
+		<DT><A HREF="https://go.dev/" ADD_DATE="1700000000" TAGS="lang">Go</A>
+		<DD>A note
+	</DL><p>
+</DL><p>`,
+			expected: []Record{
+				{
+					URL:       "https://go.dev/",
+					Title:     "Go",
+					Note:      "A note",
+					Tags:      []string{"lang", "Dev"},
+					Timestamp: time.Unix(1700000000, 0).UTC(),
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		parse, ok := Lookup(test.format)
+		if !ok {
+			t.Fatalf("Lookup(%q) found no parser", test.format)
+		}
+
+		records, err := parse(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(records, test.expected) {
+			t.Errorf("%s: got\n%#v\nexpected\n%#v", test.format, records, test.expected)
+		}
+	}
+}
+
+func TestParseCSVMissingColumn(t *testing.T) {
+	_, err := ParseInstapaper(strings.NewReader("Title,Folder\nGo,Unread\n"))
+	if err == nil {
+		t.Error("expected an error for a CSV file without a URL column")
+	}
+}
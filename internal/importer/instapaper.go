@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Instapaper's built-in folders. Links in any other folder are tagged with
+// the folder name.
+const (
+	instapaperUnread  = "Unread"
+	instapaperArchive = "Archive"
+	instapaperStarred = "Starred"
+)
+
+// ParseInstapaper reads the CSV export of Instapaper, which has URL, Title,
+// Selection, Folder and Timestamp columns and, in newer exports, Tags.
+func ParseInstapaper(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	err := readCSV(r, []string{"url"}, func(row csvRow) {
+		record := Record{
+			URL:   row.get("url"),
+			Title: row.get("title"),
+			Note:  row.get("selection"),
+			Tags:  parseInstapaperTags(row.get("tags")),
+		}
+
+		switch folder := row.get("folder"); folder {
+		case "", instapaperUnread, instapaperStarred:
+		case instapaperArchive:
+			record.Read = true
+		default:
+			record.Tags = append(record.Tags, folderTag([]string{folder}))
+		}
+
+		if ts, err := strconv.ParseInt(row.get("timestamp"), 10, 64); err == nil && ts > 0 {
+			record.Timestamp = time.Unix(ts, 0).UTC()
+		}
+
+		records = append(records, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// parseInstapaperTags reads the Tags column, which holds a JSON array of tag
+// names.
+func parseInstapaperTags(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	var tags []string
+	if err := json.Unmarshal([]byte(value), &tags); err != nil {
+		return splitList(value, ",")
+	}
+
+	return tags
+}
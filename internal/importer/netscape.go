@@ -0,0 +1,33 @@
+package importer
+
+import (
+	"io"
+
+	"linkstowr/internal/netscape"
+)
+
+// ParseNetscape reads a bookmarks.html file. Folders become nested tags.
+func ParseNetscape(r io.Reader) ([]Record, error) {
+	bookmarks, err := netscape.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		tags := bookmark.Tags
+		if len(bookmark.Folders) > 0 {
+			tags = append(tags, folderTag(bookmark.Folders))
+		}
+
+		records = append(records, Record{
+			URL:       bookmark.URL,
+			Title:     bookmark.Title,
+			Note:      bookmark.Note,
+			Tags:      tags,
+			Timestamp: bookmark.AddDate,
+		})
+	}
+
+	return records, nil
+}
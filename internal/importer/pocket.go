@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParsePocket reads the ril_export.html file of a Pocket export. Its links
+// are listed under an "Unread" and a "Read Archive" heading.
+func ParsePocket(r io.Reader) ([]Record, error) {
+	z := html.NewTokenizer(r)
+
+	var (
+		records []Record
+		text    strings.Builder
+		inTitle atom.Atom
+		read    bool
+	)
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return records, nil
+			}
+			return nil, z.Err()
+
+		case html.TextToken:
+			if inTitle != 0 {
+				text.Write(z.Text())
+			}
+
+		case html.StartTagToken:
+			token := z.Token()
+
+			switch token.DataAtom {
+			case atom.H1:
+				text.Reset()
+				inTitle = atom.H1
+
+			case atom.A:
+				text.Reset()
+				inTitle = atom.A
+
+				var record Record
+				for _, a := range token.Attr {
+					switch a.Key {
+					case "href":
+						record.URL = strings.TrimSpace(a.Val)
+					case "tags":
+						record.Tags = splitList(a.Val, ",")
+					case "time_added":
+						if ts, err := strconv.ParseInt(a.Val, 10, 64); err == nil && ts > 0 {
+							record.Timestamp = time.Unix(ts, 0).UTC()
+						}
+					}
+				}
+				record.Read = read
+				records = append(records, record)
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+
+			switch atom.Lookup(name) {
+			case atom.H1:
+				if inTitle == atom.H1 {
+					read = strings.Contains(strings.ToLower(text.String()), "archive")
+					inTitle = 0
+				}
+
+			case atom.A:
+				if inTitle == atom.A && len(records) > 0 {
+					records[len(records)-1].Title = strings.TrimSpace(text.String())
+					inTitle = 0
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package importer
+
+import (
+	"io"
+	"time"
+)
+
+// raindropUnsorted is the folder of Raindrop links that aren't in a
+// collection.
+const raindropUnsorted = "Unsorted"
+
+// ParseRaindrop reads the CSV export of Raindrop.io. Collections become
+// nested tags. Raindrop has no read state, so every record is unread.
+func ParseRaindrop(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	err := readCSV(r, []string{"url"}, func(row csvRow) {
+		record := Record{
+			URL:   row.get("url"),
+			Title: row.get("title"),
+			Note:  row.get("note"),
+			Tags:  splitList(row.get("tags"), ","),
+		}
+
+		if folder := row.get("folder"); folder != "" && folder != raindropUnsorted {
+			record.Tags = append(record.Tags, folderTag(splitList(folder, "/")))
+		}
+
+		if created := row.get("created"); created != "" {
+			if t, err := time.Parse(time.RFC3339, created); err == nil {
+				record.Timestamp = t.UTC()
+			}
+		}
+
+		records = append(records, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,255 @@
+// Package linktemplate renders links as text, such as markdown for Obsidian,
+// through user-defined text/template templates. Templates come from users, so
+// their execution is limited in time and output size.
+package linktemplate
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// maxSteps is how many loop iterations and template calls an execution can
+// take, so that templates that loop without writing anything still end.
+const maxSteps = 1 << 20
+
+// stepFunc is the function called at every step. Templates can't call it
+// themselves, since it is only defined when they are executed.
+const stepFunc = "step"
+
+var (
+	// ErrOutputTooLarge is returned when a template writes more than the
+	// allowed output size.
+	ErrOutputTooLarge = errors.New("template output is too large")
+
+	// ErrTooManySteps is returned when a template takes more than maxSteps
+	// steps.
+	ErrTooManySteps = errors.New("template takes too many steps")
+)
+
+// Definition is the source of a template. Templates with PerLink set are
+// executed once per link, the others once for the whole list of links.
+type Definition struct {
+	Name    string
+	Body    string
+	PerLink bool
+}
+
+// Builtins are the templates every user has. Their names can't be used for
+// user templates.
+var Builtins = []Definition{
+	{
+		Name: "bullets",
+		Body: `{{range .Links -}}
+- [{{linktext .Title}}]({{.URL}}){{range .Tags}} {{hashtag .}}{{end}}
+{{- with .Note}}
+  {{oneline .}}
+{{- end}}
+{{end -}}
+`,
+	},
+	{
+		Name: "table",
+		Body: `| Title | URL | Tags | Saved |
+| --- | --- | --- | --- |
+{{range .Links -}}
+| {{cell .Title}} | {{cell .URL}} | {{cell (join .Tags ", ")}} | {{date "2006-01-02" .BookmarkedAt}} |
+{{end -}}
+`,
+	},
+	{
+		Name:    "frontmatter",
+		PerLink: true,
+		Body: `---
+title: {{quote .Title}}
+url: {{quote .URL}}
+tags:
+{{- range .Tags}}
+  - {{quote .}}
+{{- end}}
+saved: {{date "2006-01-02T15:04:05Z07:00" .BookmarkedAt}}
+---
+
+# {{.Title}}
+{{with .Note}}
+{{.}}
+{{end -}}
+{{with .Highlights}}
+## Highlights
+{{range .}}
+> {{oneline .Quote}}
+{{- with .Comment}}
+
+{{.}}
+{{- end}}
+{{end -}}
+{{end -}}
+`,
+	},
+}
+
+// Builtin returns the built-in template with the given name.
+func Builtin(name string) (Definition, bool) {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def, true
+		}
+	}
+
+	return Definition{}, false
+}
+
+var funcs = template.FuncMap{
+	"join": func(elems []string, sep string) string {
+		return strings.Join(elems, sep)
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// quote returns a double-quoted string that is also valid YAML.
+	"quote": strconv.Quote,
+	// oneline collapses all whitespace, including line breaks, into single
+	// spaces.
+	"oneline": func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	},
+	// linktext escapes the text of a markdown link.
+	"linktext": strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "\n", " ").Replace,
+	// cell escapes a markdown table cell.
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>").Replace(strings.TrimSpace(s))
+	},
+	// hashtag writes a tag in Obsidian's #tag syntax, which doesn't allow
+	// spaces.
+	"hashtag": func(tag string) string {
+		return "#" + strings.Join(strings.Fields(tag), "-")
+	},
+}
+
+// Parse parses a template. Referencing a missing map key is an error, so
+// typos surface when the template is validated.
+func Parse(def Definition) (*template.Template, error) {
+	tmpl, err := template.New(def.Name).Funcs(funcs).Option("missingkey=error").Parse(def.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Root == nil {
+			continue
+		}
+
+		addSteps(t.Root)
+		prependStep(t.Root)
+	}
+
+	return tmpl, nil
+}
+
+// Execute runs a template and returns its output. It fails with
+// ErrOutputTooLarge once the output grows past maxSize bytes, with
+// ErrTooManySteps once it takes too many steps, and with the context's error
+// once it is done.
+func Execute(ctx context.Context, t *template.Template, data any, maxSize int) ([]byte, error) {
+	t, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := 0
+	t.Funcs(template.FuncMap{
+		stepFunc: func() (string, error) {
+			steps++
+			if steps > maxSteps {
+				return "", ErrTooManySteps
+			}
+			return "", ctx.Err()
+		},
+	})
+
+	w := &limitedBuffer{ctx: ctx, max: maxSize}
+
+	if err := t.Execute(w, data); err != nil {
+		for _, limitErr := range []error{ErrOutputTooLarge, ErrTooManySteps} {
+			if errors.Is(err, limitErr) {
+				return nil, limitErr
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+
+	return w.buf, nil
+}
+
+// addSteps calls stepFunc at the start of every range iteration in list,
+// including nested ones.
+func addSteps(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.IfNode:
+			addSteps(node.List)
+			addSteps(node.ElseList)
+		case *parse.WithNode:
+			addSteps(node.List)
+			addSteps(node.ElseList)
+		case *parse.RangeNode:
+			addSteps(node.List)
+			addSteps(node.ElseList)
+			prependStep(node.List)
+		}
+	}
+}
+
+// prependStep calls stepFunc before the rest of list. It prints nothing.
+func prependStep(list *parse.ListNode) {
+	step := &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      list.Pos,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      list.Pos,
+			Cmds: []*parse.CommandNode{{
+				NodeType: parse.NodeCommand,
+				Pos:      list.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(stepFunc).SetPos(list.Pos)},
+			}},
+		},
+	}
+
+	list.Nodes = append([]parse.Node{step}, list.Nodes...)
+}
+
+// limitedBuffer collects template output until it reaches max bytes or its
+// context is done.
+type limitedBuffer struct {
+	ctx context.Context
+	max int
+	buf []byte
+}
+
+func (w *limitedBuffer) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(w.buf)+len(p) > w.max {
+		return 0, ErrOutputTooLarge
+	}
+
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,222 @@
+package linktemplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type highlight struct {
+	Quote   string
+	Comment string
+}
+
+type link struct {
+	Title        string
+	URL          string
+	Note         string
+	Tags         []string
+	BookmarkedAt time.Time
+	Highlights   []highlight
+}
+
+var links = []link{
+	{
+		Title:        "The [Go] Programming Language",
+		URL:          "https://go.dev/",
+		Note:         "Fast |\nsimple",
+		Tags:         []string{"dev/go", "to read"},
+		BookmarkedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Highlights: []highlight{
+			{Quote: "Go is an open source\nprogramming language", Comment: "Worth quoting"},
+			{Quote: "simple, secure"},
+		},
+	},
+	{
+		Title:        "SQLite",
+		URL:          "https://sqlite.org/",
+		BookmarkedAt: time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC),
+	},
+}
+
+func render(t *testing.T, name string, data any) string {
+	t.Helper()
+
+	def, ok := Builtin(name)
+	if !ok {
+		t.Fatalf("Builtin(%q) not found", name)
+	}
+
+	tmpl, err := Parse(def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Execute(context.Background(), tmpl, data, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(out)
+}
+
+func TestBuiltins(t *testing.T) {
+	list := map[string][]link{"Links": links}
+
+	tests := []struct {
+		name     string
+		data     any
+		expected string
+	}{
+		{
+			name: "bullets",
+			data: list,
+			expected: `- [The \[Go\] Programming Language](https://go.dev/) #dev/go #to-read
+  Fast | simple
+- [SQLite](https://sqlite.org/)
+`,
+		},
+		{
+			name: "table",
+			data: list,
+			expected: `| Title | URL | Tags | Saved |
+| --- | --- | --- | --- |
+| The [Go] Programming Language | https://go.dev/ | dev/go, to read | 2024-03-01 |
+| SQLite | https://sqlite.org/ |  | 2024-02-01 |
+`,
+		},
+		{
+			name: "frontmatter",
+			data: links[0],
+			expected: `---
+title: "The [Go] Programming Language"
+url: "https://go.dev/"
+tags:
+  - "dev/go"
+  - "to read"
+saved: 2024-03-01T12:00:00Z
+---
+
+# The [Go] Programming Language
+
+Fast |
+simple
+
+## Highlights
+
+> Go is an open source programming language
+
+Worth quoting
+
+> simple, secure
+`,
+		},
+		{
+			name: "frontmatter",
+			data: links[1],
+			expected: `---
+title: "SQLite"
+url: "https://sqlite.org/"
+tags:
+saved: 2024-02-01T08:30:00Z
+---
+
+# SQLite
+`,
+		},
+	}
+
+	for _, test := range tests {
+		if actual := render(t, test.name, test.data); actual != test.expected {
+			t.Errorf("%s rendered\n%s\nexpected\n%s", test.name, actual, test.expected)
+		}
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	tmpl, err := Parse(Definition{Name: "big", Body: `{{range .}}{{.}}{{end}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]string, 100)
+	for i := range data {
+		data[i] = "0123456789"
+	}
+
+	if _, err := Execute(context.Background(), tmpl, data, 500); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("Execute() error = %v, expected ErrOutputTooLarge", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Execute(ctx, tmpl, data, 1<<20); !errors.Is(err, context.Canceled) {
+		t.Errorf("Execute() error = %v, expected context.Canceled", err)
+	}
+}
+
+func TestExecuteSteps(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		data any
+	}{
+		{
+			name: "range over int",
+			body: `{{range $i := 9000000000000}}{{end}}`,
+		},
+		{
+			name: "nested ranges",
+			body: `{{range .}}{{range $}}{{range $}}{{range $}}{{end}}{{end}}{{end}}{{end}}`,
+			data: make([]int, 100),
+		},
+		{
+			name: "recursive calls",
+			body: doublingCalls(30),
+		},
+	}
+
+	for _, test := range tests {
+		tmpl, err := Parse(Definition{Name: test.name, Body: test.body})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before := runtime.NumGoroutine()
+
+		if _, err := Execute(context.Background(), tmpl, test.data, 1<<20); !errors.Is(err, ErrTooManySteps) {
+			t.Errorf("%s: Execute() error = %v, expected ErrTooManySteps", test.name, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if _, err := Execute(ctx, tmpl, test.data, 1<<20); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: Execute() error = %v, expected context.DeadlineExceeded", test.name, err)
+		}
+		cancel()
+
+		if after := runtime.NumGoroutine(); after > before {
+			t.Errorf("%s: %d goroutines left running after Execute()", test.name, after-before)
+		}
+	}
+}
+
+func TestStepNotCallable(t *testing.T) {
+	if _, err := Parse(Definition{Name: "step", Body: `{{step}}`}); err == nil {
+		t.Error("Parse() error = nil, expected the step function to be undefined")
+	}
+}
+
+// doublingCalls returns a template whose nth defined template calls the one
+// before it twice, which makes 2^n calls without any loop.
+func doublingCalls(n int) string {
+	body := `{{define "t0"}}{{end}}`
+	for i := 1; i <= n; i++ {
+		body += fmt.Sprintf(`{{define "t%d"}}{{template "t%d"}}{{template "t%d"}}{{end}}`, i, i-1, i-1)
+	}
+
+	return body + fmt.Sprintf(`{{template "t%d"}}`, n)
+}
@@ -0,0 +1,55 @@
+// Package mail sends email, such as address verification links.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer set by the MAILER environment variable. "smtp" sends
+// email through SMTP_ADDR, which can be a local SMTP sink during development;
+// "log" (the default) writes email to MAIL_LOG_FILE, or to standard output if
+// it isn't set.
+func New() (Mailer, error) {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		mailer := &SMTPMailer{
+			Addr:     os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if mailer.Addr == "" || mailer.From == "" {
+			return nil, fmt.Errorf("SMTP_ADDR and SMTP_FROM must be set to send email through SMTP")
+		}
+
+		return mailer, nil
+	case "log", "":
+		path := os.Getenv("MAIL_LOG_FILE")
+		if path == "" {
+			return NewLogMailer(os.Stdout), nil
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLogMailer(f), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", os.Getenv("MAILER"))
+	}
+}
@@ -0,0 +1,134 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildEmail(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	email := string(buildEmail("app@example.com", "admin@example.com", "Reset\r\nBcc: evil@example.com", "line 1\nline 2", date))
+
+	expected := "From: app@example.com\r\n" +
+		"To: admin@example.com\r\n" +
+		"Subject: Reset Bcc: evil@example.com\r\n" +
+		"Date: Wed, 01 May 2024 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line 1\r\nline 2\r\n"
+	if email != expected {
+		t.Errorf("buildEmail() = %q, expected %q", email, expected)
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var b bytes.Buffer
+	m := NewLogMailer(&b)
+
+	err := m.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if !strings.Contains(b.String(), "To: alice@example.com\nSubject: Hello\n\nBody\n") {
+		t.Errorf("Send() wrote %q", b.String())
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(t, l, received)
+
+	m := &SMTPMailer{Addr: l.Addr().String(), From: "app@example.com"}
+	err = m.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hello", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	data := <-received
+	if !strings.Contains(data, "To: alice@example.com\r\nSubject: Hello\r\n") || !strings.HasSuffix(data, "\r\nBody\r\n") {
+		t.Errorf("server received %q", data)
+	}
+}
+
+func TestSMTPMailerContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The server accepts the connection but never greets the client
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	m := &SMTPMailer{Addr: l.Addr().String(), From: "app@example.com"}
+	if err := m.Send(ctx, Message{To: "alice@example.com", Subject: "Hello", Body: "Body"}); err == nil {
+		t.Error("Send() to a stalled server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Send() took %v after its context was done", elapsed)
+	}
+}
+
+// serveSMTP is an SMTP sink. It accepts one connection and answers just
+// enough of SMTP to receive a message, which it sends to received.
+func serveSMTP(t *testing.T, l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			// The dot reader turns line endings into \n
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				t.Error(err)
+			}
+			received <- strings.ReplaceAll(string(data), "\n", "\r\n")
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package netscape reads and writes the Netscape bookmark file format, the
+// bookmarks.html that Chrome, Firefox, Safari, Pinboard and most other bookmark
+// managers import and export.
+package netscape
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Bookmark is a single <A> entry of a bookmark file.
+type Bookmark struct {
+	URL   string
+	Title string
+	// Note is the text of the <DD> following the bookmark, if any.
+	Note string
+	// Folders is the path of folders containing the bookmark, outermost
+	// first. Browser root folders such as the bookmarks toolbar are left out.
+	Folders []string
+	// Tags are the comma-separated values of the TAGS attribute written by
+	// Pinboard and Firefox.
+	Tags []string
+	// AddDate is zero if the bookmark has no valid ADD_DATE.
+	AddDate time.Time
+}
+
+// rootFolderAttrs mark the folders browsers create themselves. Their names
+// differ between browsers and languages and say nothing about the bookmarks
+// in them.
+var rootFolderAttrs = []string{"personal_toolbar_folder", "unfiled_bookmarks_folder"}
+
+// Parse reads all bookmarks from a bookmark file. The format is loosely
+// specified and rarely well-formed HTML, so Parse only relies on the order of
+// the H3, DL, A and DD tags and ignores everything else.
+func Parse(r io.Reader) ([]Bookmark, error) {
+	z := html.NewTokenizer(r)
+
+	var (
+		bookmarks []Bookmark
+		// folders holds one entry per open DL; the entry is empty for lists
+		// that don't belong to a named folder.
+		folders []string
+		// pendingFolder is the name of the last H3, which names the next DL.
+		// It stays empty for browser root folders.
+		pendingFolder string
+		// text collects the contents of the current H3, A or DD.
+		text    strings.Builder
+		inTitle atom.Atom
+		// isRootFolder is set while reading the H3 of a browser root folder.
+		isRootFolder bool
+		inNote       bool
+		// last is the index of the bookmark a following DD belongs to.
+		last = -1
+	)
+
+	finishNote := func() {
+		if inNote && last >= 0 {
+			bookmarks[last].Note = strings.TrimSpace(text.String())
+		}
+		inNote = false
+	}
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				finishNote()
+				return bookmarks, nil
+			}
+			return nil, z.Err()
+
+		case html.TextToken:
+			if inTitle != 0 || inNote {
+				text.Write(z.Text())
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+
+			switch token.DataAtom {
+			case atom.H3:
+				finishNote()
+				text.Reset()
+				inTitle = atom.H3
+				isRootFolder = hasAnyAttr(token, rootFolderAttrs)
+				pendingFolder = ""
+				last = -1
+
+			case atom.A:
+				finishNote()
+				text.Reset()
+				inTitle = atom.A
+				bookmarks = append(bookmarks, Bookmark{
+					URL:     strings.TrimSpace(attr(token, "href")),
+					Folders: folderPath(folders),
+					Tags:    splitTags(attr(token, "tags")),
+					AddDate: parseTimestamp(attr(token, "add_date")),
+				})
+				last = len(bookmarks) - 1
+
+			case atom.Dd:
+				finishNote()
+				text.Reset()
+				inNote = true
+
+			case atom.Dl:
+				finishNote()
+				folders = append(folders, pendingFolder)
+				pendingFolder = ""
+
+			case atom.Dt:
+				finishNote()
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+
+			switch atom.Lookup(name) {
+			case atom.H3:
+				if inTitle == atom.H3 {
+					if !isRootFolder {
+						pendingFolder = strings.TrimSpace(text.String())
+					}
+					inTitle = 0
+				}
+
+			case atom.A:
+				if inTitle == atom.A && last >= 0 {
+					bookmarks[last].Title = strings.TrimSpace(text.String())
+					inTitle = 0
+				}
+
+			case atom.Dl:
+				finishNote()
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			}
+		}
+	}
+}
+
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func hasAnyAttr(token html.Token, keys []string) bool {
+	for _, key := range keys {
+		if attr(token, key) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func folderPath(folders []string) []string {
+	path := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		if folder != "" {
+			path = append(path, folder)
+		}
+	}
+
+	return path
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// parseTimestamp reads an ADD_DATE, which is in seconds since the epoch.
+// Some exporters write milliseconds or microseconds instead.
+func parseTimestamp(value string) time.Time {
+	ts, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}
+	}
+
+	switch {
+	case ts > 1e14:
+		return time.UnixMicro(ts).UTC()
+	case ts > 1e11:
+		return time.UnixMilli(ts).UTC()
+	default:
+		return time.Unix(ts, 0).UTC()
+	}
+}
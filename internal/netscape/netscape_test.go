@@ -0,0 +1,122 @@
+package netscape
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chromeExport = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000100">The Go &amp; Programming Language</A>
+        <DT><H3 ADD_DATE="1700000000">Dev</H3>
+        <DL><p>
+            <DT><H3>Go</H3>
+            <DL><p>
+                <DT><A HREF="https://pkg.go.dev/" ADD_DATE="1700000200" TAGS="docs, reference">Go Packages</A>
+                <DD>Where to find
+                modules
+            </DL><p>
+            <DT><A HREF="https://sqlite.org/">SQLite</A>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF=" https://example.com/ " ADD_DATE="1700000300000">Example</A>
+</DL><p>
+`
+
+func TestParse(t *testing.T) {
+	bookmarks, err := Parse(strings.NewReader(chromeExport))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Bookmark{
+		{
+			URL:     "https://go.dev/",
+			Title:   "The Go & Programming Language",
+			Folders: []string{},
+			AddDate: time.Unix(1700000100, 0).UTC(),
+		},
+		{
+			URL:     "https://pkg.go.dev/",
+			Title:   "Go Packages",
+			Note:    "Where to find\n                modules",
+			Folders: []string{"Dev", "Go"},
+			Tags:    []string{"docs", "reference"},
+			AddDate: time.Unix(1700000200, 0).UTC(),
+		},
+		{
+			URL:     "https://sqlite.org/",
+			Title:   "SQLite",
+			Folders: []string{"Dev"},
+		},
+		{
+			URL:     "https://example.com/",
+			Title:   "Example",
+			Folders: []string{},
+			AddDate: time.Unix(1700000300, 0).UTC(),
+		},
+	}
+
+	if !reflect.DeepEqual(bookmarks, expected) {
+		t.Errorf("Parse() =\n%#v\nexpected\n%#v", bookmarks, expected)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	bookmarks := []Bookmark{
+		{
+			URL:     "https://example.com/?a=1&b=2",
+			Title:   "<Example>",
+			Folders: []string{},
+			AddDate: time.Unix(1700000000, 0).UTC(),
+		},
+		{
+			URL:     "https://go.dev/",
+			Title:   "Go",
+			Note:    `Says "hello" & more`,
+			Folders: []string{"dev", "go"},
+			Tags:    []string{"dev/go", "lang"},
+			AddDate: time.Unix(1700000100, 0).UTC(),
+		},
+		{
+			URL:     "https://sqlite.org/",
+			Title:   "SQLite",
+			Folders: []string{"dev"},
+		},
+		{
+			URL:     "https://news.ycombinator.com/",
+			Title:   "HN",
+			Folders: []string{"news"},
+		},
+	}
+
+	var buf strings.Builder
+	w := NewWriter(&buf)
+	for _, b := range bookmarks {
+		if err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(parsed, bookmarks) {
+		t.Errorf("Parse(Write()) =\n%#v\nexpected\n%#v\n%s", parsed, bookmarks, buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package netscape
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const header = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+`
+
+// Writer writes a bookmark file one bookmark at a time. Bookmarks in the
+// same folder have to be written one after another, since a folder can't be
+// reopened once a bookmark outside of it has been written.
+type Writer struct {
+	w       *bufio.Writer
+	folders []string
+	started bool
+	err     error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Write adds a bookmark to the file, closing and opening folders as needed.
+func (w *Writer) Write(b Bookmark) error {
+	w.start()
+
+	common := 0
+	for common < len(w.folders) && common < len(b.Folders) && w.folders[common] == b.Folders[common] {
+		common++
+	}
+
+	w.closeFolders(common)
+
+	for _, folder := range b.Folders[common:] {
+		w.line("<DT><H3>" + html.EscapeString(folder) + "</H3>")
+		w.line("<DL><p>")
+		w.folders = append(w.folders, folder)
+	}
+
+	var a strings.Builder
+	fmt.Fprintf(&a, `<DT><A HREF="%s"`, html.EscapeString(b.URL))
+	if !b.AddDate.IsZero() {
+		fmt.Fprintf(&a, ` ADD_DATE="%d"`, b.AddDate.Unix())
+	}
+	if len(b.Tags) > 0 {
+		fmt.Fprintf(&a, ` TAGS="%s"`, html.EscapeString(strings.Join(b.Tags, ",")))
+	}
+	fmt.Fprintf(&a, ">%s</A>", html.EscapeString(b.Title))
+	w.line(a.String())
+
+	if b.Note != "" {
+		w.line("<DD>" + html.EscapeString(b.Note))
+	}
+
+	return w.err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.w.Flush()
+}
+
+// Close closes all open folders and flushes the file. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	w.start()
+
+	w.closeFolders(0)
+	w.write("</DL><p>\n")
+
+	return w.Flush()
+}
+
+// closeFolders closes the innermost open folders until depth are left open.
+func (w *Writer) closeFolders(depth int) {
+	for len(w.folders) > depth {
+		w.folders = w.folders[:len(w.folders)-1]
+		w.line("</DL><p>")
+	}
+}
+
+func (w *Writer) start() {
+	if !w.started {
+		w.write(header)
+		w.started = true
+	}
+}
+
+// line writes a line indented to the current folder depth.
+func (w *Writer) line(s string) {
+	w.write(strings.Repeat("    ", len(w.folders)+1) + s + "\n")
+}
+
+func (w *Writer) write(s string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(s)
+	}
+}
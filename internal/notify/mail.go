@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"linkstowr/internal/mail"
+)
+
+// MailNotifier emails messages to users. Messages for users without a
+// verified email address go to FallbackAddr, such as the admin's, who passes
+// them on.
+type MailNotifier struct {
+	Mailer       mail.Mailer
+	FallbackAddr string
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, msg Message) error {
+	to := msg.Email
+	subject := msg.Subject
+	if to == "" {
+		if n.FallbackAddr == "" {
+			return fmt.Errorf("%s has no verified email address and NOTIFY_MAIL_TO isn't set", msg.Username)
+		}
+
+		to = n.FallbackAddr
+		subject = fmt.Sprintf("%s (%s)", msg.Subject, msg.Username)
+	}
+
+	return n.Mailer.Send(ctx, mail.Message{
+		To:      to,
+		Subject: subject,
+		Body:    msg.Body,
+	})
+}
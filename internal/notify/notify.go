@@ -0,0 +1,52 @@
+// Package notify delivers messages to users outside of the API, such as
+// password reset tokens.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"linkstowr/internal/mail"
+)
+
+// Message is a notification for a user. Email is the user's verified email
+// address, if they have one.
+type Message struct {
+	Username string
+	Email    string
+	Subject  string
+	Body     string
+}
+
+// Notifier sends messages to users.
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// New returns the notifier set by the NOTIFIER environment variable. "mail"
+// emails messages with mailer, "log" (the default) writes them to
+// NOTIFY_LOG_FILE, or to standard output if it isn't set.
+func New(mailer mail.Mailer) (Notifier, error) {
+	switch os.Getenv("NOTIFIER") {
+	case "mail":
+		return &MailNotifier{
+			Mailer:       mailer,
+			FallbackAddr: os.Getenv("NOTIFY_MAIL_TO"),
+		}, nil
+	case "log", "":
+		path := os.Getenv("NOTIFY_LOG_FILE")
+		if path == "" {
+			return NewLogNotifier(os.Stdout), nil
+		}
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewLogNotifier(f), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q", os.Getenv("NOTIFIER"))
+	}
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"linkstowr/internal/mail"
+)
+
+func TestLogNotifier(t *testing.T) {
+	var b bytes.Buffer
+	n := NewLogNotifier(&b)
+
+	err := n.Notify(context.Background(), Message{Username: "alice", Subject: "Hello", Body: "Body"})
+	if err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if !strings.Contains(b.String(), "To: alice\nSubject: Hello\n\nBody\n") {
+		t.Errorf("Notify() wrote %q", b.String())
+	}
+}
+
+type recordingMailer struct {
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestMailNotifier(t *testing.T) {
+	mailer := &recordingMailer{}
+	n := &MailNotifier{Mailer: mailer, FallbackAddr: "admin@example.com"}
+
+	messages := []Message{
+		{Username: "alice", Email: "alice@example.com", Subject: "Hello", Body: "Body"},
+		{Username: "bob", Subject: "Hello", Body: "Body"},
+	}
+	for _, msg := range messages {
+		if err := n.Notify(context.Background(), msg); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	expected := []mail.Message{
+		{To: "alice@example.com", Subject: "Hello", Body: "Body"},
+		{To: "admin@example.com", Subject: "Hello (bob)", Body: "Body"},
+	}
+	for i := range expected {
+		if mailer.sent[i] != expected[i] {
+			t.Errorf("sent %+v, expected %+v", mailer.sent[i], expected[i])
+		}
+	}
+
+	n.FallbackAddr = ""
+	if err := n.Notify(context.Background(), messages[1]); err == nil {
+		t.Error("Notify() without an address succeeded")
+	}
+}
@@ -9,26 +9,146 @@ import (
 	"time"
 )
 
+type Collection struct {
+	ID        int64         `json:"id"`
+	UserID    int64         `json:"user_id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type CollectionLink struct {
+	LinkID       int64     `json:"link_id"`
+	CollectionID int64     `json:"collection_id"`
+	Position     int64     `json:"position"`
+	AddedAt      time.Time `json:"added_at"`
+}
+
+type Highlight struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	UserID    int64          `json:"user_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
 type Link struct {
-	ID           int64          `json:"id"`
-	Url          string         `json:"url"`
-	Title        string         `json:"title"`
-	Note         sql.NullString `json:"note"`
-	UserID       int64          `json:"user_id"`
-	BookmarkedAt time.Time      `json:"bookmarked_at"`
-	Tags         sql.NullString `json:"tags"`
+	ID                   int64          `json:"id"`
+	Url                  string         `json:"url"`
+	Title                string         `json:"title"`
+	Note                 sql.NullString `json:"note"`
+	UserID               int64          `json:"user_id"`
+	BookmarkedAt         time.Time      `json:"bookmarked_at"`
+	Tags                 sql.NullString `json:"tags"`
+	LeaseID              sql.NullString `json:"lease_id"`
+	LeaseExpiresAt       sql.NullTime   `json:"lease_expires_at"`
+	Domain               sql.NullString `json:"domain"`
+	CanonicalUrl         sql.NullString `json:"canonical_url"`
+	GroupID              sql.NullString `json:"group_id"`
+	DeletedAt            sql.NullTime   `json:"deleted_at"`
+	ReadAt               sql.NullTime   `json:"read_at"`
+	ArchivedAt           sql.NullTime   `json:"archived_at"`
+	Starred              bool           `json:"starred"`
+	PinnedPosition       sql.NullInt64  `json:"pinned_position"`
+	CanonicalUrlConflict bool           `json:"canonical_url_conflict"`
+	ConsumedAt           sql.NullTime   `json:"consumed_at"`
+}
+
+type LinkTag struct {
+	LinkID int64 `json:"link_id"`
+	TagID  int64 `json:"tag_id"`
+}
+
+type LinksFt struct {
+	Title string `json:"title"`
+	Note  string `json:"note"`
+	Url   string `json:"url"`
+	Tags  string `json:"tags"`
+}
+
+type MfaChallenge struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	Attempts  int64     `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type PasswordResetToken struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	TokenHash string       `json:"token_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RecoveryCode struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Session struct {
+	ID               int64          `json:"id"`
+	UserID           int64          `json:"user_id"`
+	FamilyID         string         `json:"family_id"`
+	RefreshTokenHash string         `json:"refresh_token_hash"`
+	CreatedAt        time.Time      `json:"created_at"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+	RotatedAt        sql.NullTime   `json:"rotated_at"`
+	RevokedAt        sql.NullTime   `json:"revoked_at"`
+	UserAgent        sql.NullString `json:"user_agent"`
+	IpAddress        sql.NullString `json:"ip_address"`
+	LastSeenAt       sql.NullTime   `json:"last_seen_at"`
+}
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Template struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Token struct {
-	ID         int64  `json:"id"`
-	TokenHash  string `json:"token_hash"`
-	Name       string `json:"name"`
-	ShortToken string `json:"short_token"`
-	UserID     int64  `json:"user_id"`
+	ID            int64        `json:"id"`
+	TokenHash     string       `json:"token_hash"`
+	Name          string       `json:"name"`
+	ShortToken    string       `json:"short_token"`
+	UserID        int64        `json:"user_id"`
+	SyncCursor    int64        `json:"sync_cursor"`
+	SyncedAt      sql.NullTime `json:"synced_at"`
+	SyncDelivered int64        `json:"sync_delivered"`
 }
 
 type User struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	ID                     int64          `json:"id"`
+	Username               string         `json:"username"`
+	Password               string         `json:"password"`
+	Email                  sql.NullString `json:"email"`
+	EmailVerifiedAt        sql.NullTime   `json:"email_verified_at"`
+	EmailVerificationNonce sql.NullString `json:"email_verification_nonce"`
+	TotpSecret             sql.NullString `json:"totp_secret"`
+	TotpEnabledAt          sql.NullTime   `json:"totp_enabled_at"`
+	TotpLastUsedStep       sql.NullInt64  `json:"totp_last_used_step"`
+	MfaFailedAttempts      int64          `json:"mfa_failed_attempts"`
+	MfaLockedUntil         sql.NullTime   `json:"mfa_locked_until"`
 }
@@ -8,31 +8,327 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
+const ackLinks = `-- name: AckLinks :execrows
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, consumed_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE user_id = ? AND lease_id = ? AND deleted_at IS NULL AND id IN (/*SLICE:ids*/?)
+`
+
+type AckLinksParams struct {
+	UserID  int64          `json:"user_id"`
+	LeaseID sql.NullString `json:"lease_id"`
+	Ids     []int64        `json:"ids"`
+}
+
+// Moves acknowledged links to the trash, where they can still be restored.
+func (q *Queries) AckLinks(ctx context.Context, arg AckLinksParams) (int64, error) {
+	query := ackLinks
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	queryParams = append(queryParams, arg.LeaseID)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const addCollectionLink = `-- name: AddCollectionLink :exec
+INSERT INTO collection_links (link_id, collection_id, position)
+VALUES (?, ?, ?)
+`
+
+type AddCollectionLinkParams struct {
+	LinkID       int64 `json:"link_id"`
+	CollectionID int64 `json:"collection_id"`
+	Position     int64 `json:"position"`
+}
+
+func (q *Queries) AddCollectionLink(ctx context.Context, arg AddCollectionLinkParams) error {
+	_, err := q.db.ExecContext(ctx, addCollectionLink, arg.LinkID, arg.CollectionID, arg.Position)
+	return err
+}
+
+const addLinkTag = `-- name: AddLinkTag :exec
+INSERT OR IGNORE INTO link_tags (link_id, tag_id)
+VALUES (?, ?)
+`
+
+type AddLinkTagParams struct {
+	LinkID int64 `json:"link_id"`
+	TagID  int64 `json:"tag_id"`
+}
+
+func (q *Queries) AddLinkTag(ctx context.Context, arg AddLinkTagParams) error {
+	_, err := q.db.ExecContext(ctx, addLinkTag, arg.LinkID, arg.TagID)
+	return err
+}
+
+const advanceTokenCursor = `-- name: AdvanceTokenCursor :exec
+UPDATE tokens
+SET sync_cursor = MIN(?, sync_delivered), synced_at = ?
+WHERE id = ? AND user_id = ? AND sync_cursor <= MIN(?, sync_delivered)
+`
+
+type AdvanceTokenCursorParams struct {
+	SyncCursor int64        `json:"sync_cursor"`
+	SyncedAt   sql.NullTime `json:"synced_at"`
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+}
+
+// The cursor doesn't move past the last link the token was sent, so that a
+// cursor that is too far ahead can't prune links nobody received.
+func (q *Queries) AdvanceTokenCursor(ctx context.Context, arg AdvanceTokenCursorParams) error {
+	_, err := q.db.ExecContext(ctx, advanceTokenCursor,
+		arg.SyncCursor,
+		arg.SyncedAt,
+		arg.ID,
+		arg.UserID,
+		arg.SyncCursor,
+	)
+	return err
+}
+
+const claimLinks = `-- name: ClaimLinks :many
+UPDATE links
+SET lease_id = ?, lease_expires_at = ?
+WHERE id IN (
+    SELECT l.id FROM links l
+    WHERE l.user_id = ? AND l.deleted_at IS NULL AND l.consumed_at IS NULL AND (l.lease_expires_at IS NULL OR l.lease_expires_at < ?)
+    ORDER BY l.id
+    LIMIT ?
+)
+RETURNING id, url, title, note, bookmarked_at, tags
+`
+
+type ClaimLinksParams struct {
+	LeaseID        sql.NullString `json:"lease_id"`
+	LeaseExpiresAt sql.NullTime   `json:"lease_expires_at"`
+	UserID         int64          `json:"user_id"`
+	Now            sql.NullTime   `json:"now"`
+	Limit          int64          `json:"limit"`
+}
+
+type ClaimLinksRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+}
+
+func (q *Queries) ClaimLinks(ctx context.Context, arg ClaimLinksParams) ([]ClaimLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimLinks,
+		arg.LeaseID,
+		arg.LeaseExpiresAt,
+		arg.UserID,
+		arg.Now,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimLinksRow
+	for rows.Next() {
+		var i ClaimLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const clearLinkTags = `-- name: ClearLinkTags :exec
+DELETE FROM link_tags
+WHERE link_id = ?
+`
+
+func (q *Queries) ClearLinkTags(ctx context.Context, linkID int64) error {
+	_, err := q.db.ExecContext(ctx, clearLinkTags, linkID)
+	return err
+}
+
 const clearLinks = `-- name: ClearLinks :exec
-DELETE FROM links
-WHERE user_id = ?
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE user_id = ? AND deleted_at IS NULL
 `
 
+// Moves all of a user's links to the trash.
 func (q *Queries) ClearLinks(ctx context.Context, userID int64) error {
 	_, err := q.db.ExecContext(ctx, clearLinks, userID)
 	return err
 }
 
+const compactPinnedLinks = `-- name: CompactPinnedLinks :exec
+UPDATE links
+SET pinned_position = pinned.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY pinned_position, id) - 1 AS position
+    FROM links
+    WHERE user_id = ? AND pinned_position IS NOT NULL
+) AS pinned
+WHERE links.id = pinned.id AND links.pinned_position <> pinned.position
+`
+
+// Numbers a user's pinned links from 0 again, closing the gaps left by links
+// that were unpinned together.
+func (q *Queries) CompactPinnedLinks(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, compactPinnedLinks, userID)
+	return err
+}
+
+const countCollectionLinks = `-- name: CountCollectionLinks :one
+SELECT COUNT(*) FROM collection_links
+WHERE collection_id = ?
+`
+
+func (q *Queries) CountCollectionLinks(ctx context.Context, collectionID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countCollectionLinks, collectionID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRecoveryCodes = `-- name: CountRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = ? AND used_at IS NULL
+`
+
+func (q *Queries) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createCollection = `-- name: CreateCollection :one
+INSERT INTO collections (user_id, parent_id, name)
+VALUES (?, ?, ?)
+RETURNING id, parent_id, name, created_at
+`
+
+type CreateCollectionParams struct {
+	UserID   int64         `json:"user_id"`
+	ParentID sql.NullInt64 `json:"parent_id"`
+	Name     string        `json:"name"`
+}
+
+type CreateCollectionRow struct {
+	ID        int64         `json:"id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (q *Queries) CreateCollection(ctx context.Context, arg CreateCollectionParams) (CreateCollectionRow, error) {
+	row := q.db.QueryRowContext(ctx, createCollection, arg.UserID, arg.ParentID, arg.Name)
+	var i CreateCollectionRow
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createHighlight = `-- name: CreateHighlight :one
+INSERT INTO highlights (link_id, user_id, quote, prefix, suffix, comment, color)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at
+`
+
+type CreateHighlightParams struct {
+	LinkID  int64          `json:"link_id"`
+	UserID  int64          `json:"user_id"`
+	Quote   string         `json:"quote"`
+	Prefix  sql.NullString `json:"prefix"`
+	Suffix  sql.NullString `json:"suffix"`
+	Comment sql.NullString `json:"comment"`
+	Color   string         `json:"color"`
+}
+
+type CreateHighlightRow struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (q *Queries) CreateHighlight(ctx context.Context, arg CreateHighlightParams) (CreateHighlightRow, error) {
+	row := q.db.QueryRowContext(ctx, createHighlight,
+		arg.LinkID,
+		arg.UserID,
+		arg.Quote,
+		arg.Prefix,
+		arg.Suffix,
+		arg.Comment,
+		arg.Color,
+	)
+	var i CreateHighlightRow
+	err := row.Scan(
+		&i.ID,
+		&i.LinkID,
+		&i.Quote,
+		&i.Prefix,
+		&i.Suffix,
+		&i.Comment,
+		&i.Color,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const createLink = `-- name: CreateLink :one
-INSERT INTO links (url, title, note, user_id, tags)
-VALUES (?, ?, ?, ?, ?)
+INSERT INTO links (url, title, note, user_id, domain, canonical_url, group_id)
+VALUES (?, ?, ?, ?, ?, ?, ?)
 RETURNING id, url
 `
 
 type CreateLinkParams struct {
-	Url    string         `json:"url"`
-	Title  string         `json:"title"`
-	Note   sql.NullString `json:"note"`
-	UserID int64          `json:"user_id"`
-	Tags   sql.NullString `json:"tags"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	UserID       int64          `json:"user_id"`
+	Domain       sql.NullString `json:"domain"`
+	CanonicalUrl sql.NullString `json:"canonical_url"`
+	GroupID      sql.NullString `json:"group_id"`
 }
 
 type CreateLinkRow struct {
@@ -46,13 +342,126 @@ func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (CreateL
 		arg.Title,
 		arg.Note,
 		arg.UserID,
-		arg.Tags,
+		arg.Domain,
+		arg.CanonicalUrl,
+		arg.GroupID,
 	)
 	var i CreateLinkRow
 	err := row.Scan(&i.ID, &i.Url)
 	return i, err
 }
 
+const createMFAChallenge = `-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+VALUES (?, ?, ?)
+`
+
+type CreateMFAChallengeParams struct {
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateMFAChallenge(ctx context.Context, arg CreateMFAChallengeParams) error {
+	_, err := q.db.ExecContext(ctx, createMFAChallenge, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	return err
+}
+
+const createPasswordResetToken = `-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+VALUES (?, ?, ?)
+`
+
+type CreatePasswordResetTokenParams struct {
+	UserID    int64     `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createPasswordResetToken, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	return err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+VALUES (?, ?)
+`
+
+type CreateRecoveryCodeParams struct {
+	UserID   int64  `json:"user_id"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (user_id, family_id, refresh_token_hash, expires_at, user_agent, ip_address, last_seen_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateSessionParams struct {
+	UserID           int64          `json:"user_id"`
+	FamilyID         string         `json:"family_id"`
+	RefreshTokenHash string         `json:"refresh_token_hash"`
+	ExpiresAt        time.Time      `json:"expires_at"`
+	UserAgent        sql.NullString `json:"user_agent"`
+	IpAddress        sql.NullString `json:"ip_address"`
+	LastSeenAt       sql.NullTime   `json:"last_seen_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession,
+		arg.UserID,
+		arg.FamilyID,
+		arg.RefreshTokenHash,
+		arg.ExpiresAt,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.LastSeenAt,
+	)
+	return err
+}
+
+const createTemplate = `-- name: CreateTemplate :one
+INSERT INTO templates (user_id, name, body, per_link)
+VALUES (?, ?, ?, ?)
+RETURNING id, name, body, per_link, created_at, updated_at
+`
+
+type CreateTemplateParams struct {
+	UserID  int64  `json:"user_id"`
+	Name    string `json:"name"`
+	Body    string `json:"body"`
+	PerLink bool   `json:"per_link"`
+}
+
+type CreateTemplateRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) CreateTemplate(ctx context.Context, arg CreateTemplateParams) (CreateTemplateRow, error) {
+	row := q.db.QueryRowContext(ctx, createTemplate, arg.UserID, arg.Name, arg.Body, arg.PerLink)
+	var i CreateTemplateRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Body,
+		&i.PerLink,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const createToken = `-- name: CreateToken :exec
 INSERT INTO tokens (token_hash, name, short_token, user_id)
 VALUES (?, ?, ?, ?)
@@ -76,14 +485,16 @@ func (q *Queries) CreateToken(ctx context.Context, arg CreateTokenParams) error
 }
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (username, password)
-VALUES (?, ?)
+INSERT INTO users (username, password, email, email_verification_nonce)
+VALUES (?, ?, ?, ?)
 RETURNING id, username
 `
 
 type CreateUserParams struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username               string         `json:"username"`
+	Password               string         `json:"password"`
+	Email                  sql.NullString `json:"email"`
+	EmailVerificationNonce sql.NullString `json:"email_verification_nonce"`
 }
 
 type CreateUserRow struct {
@@ -92,120 +503,2239 @@ type CreateUserRow struct {
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.Password)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Username,
+		arg.Password,
+		arg.Email,
+		arg.EmailVerificationNonce,
+	)
 	var i CreateUserRow
 	err := row.Scan(&i.ID, &i.Username)
 	return i, err
 }
 
-const deleteToken = `-- name: DeleteToken :exec
-DELETE FROM tokens
-WHERE id = ? AND user_id = ?
+const deleteCollection = `-- name: DeleteCollection :execrows
+DELETE FROM collections
+WHERE id IN (
+    WITH RECURSIVE tree(id) AS (
+        SELECT c.id FROM collections c
+        WHERE c.id = ? AND c.user_id = ?
+        UNION ALL
+        SELECT c.id FROM collections c
+        JOIN tree ON c.parent_id = tree.id
+    )
+    SELECT id FROM tree
+)
 `
 
-type DeleteTokenParams struct {
+type DeleteCollectionParams struct {
 	ID     int64 `json:"id"`
 	UserID int64 `json:"user_id"`
 }
 
-func (q *Queries) DeleteToken(ctx context.Context, arg DeleteTokenParams) error {
-	_, err := q.db.ExecContext(ctx, deleteToken, arg.ID, arg.UserID)
-	return err
+// Deletes a collection along with all collections nested in it.
+func (q *Queries) DeleteCollection(ctx context.Context, arg DeleteCollectionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteCollection, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const getToken = `-- name: GetToken :one
-SELECT id, name, user_id FROM tokens
-WHERE token_hash = ?
+const deleteHighlight = `-- name: DeleteHighlight :execrows
+DELETE FROM highlights
+WHERE id = ? AND link_id = ? AND user_id = ?
 `
 
-type GetTokenRow struct {
-	ID     int64  `json:"id"`
-	Name   string `json:"name"`
-	UserID int64  `json:"user_id"`
+type DeleteHighlightParams struct {
+	ID     int64 `json:"id"`
+	LinkID int64 `json:"link_id"`
+	UserID int64 `json:"user_id"`
 }
 
-func (q *Queries) GetToken(ctx context.Context, tokenHash string) (GetTokenRow, error) {
-	row := q.db.QueryRowContext(ctx, getToken, tokenHash)
-	var i GetTokenRow
-	err := row.Scan(&i.ID, &i.Name, &i.UserID)
-	return i, err
+func (q *Queries) DeleteHighlight(ctx context.Context, arg DeleteHighlightParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteHighlight, arg.ID, arg.LinkID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const getUser = `-- name: GetUser :one
-SELECT id, username, password FROM users
-WHERE username = ?
+const deleteLink = `-- name: DeleteLink :execrows
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
 `
 
-func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUser, username)
-	var i User
-	err := row.Scan(&i.ID, &i.Username, &i.Password)
-	return i, err
+type DeleteLinkParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
 }
 
-const listLinks = `-- name: ListLinks :many
-SELECT url, title, note, bookmarked_at, tags FROM links
-WHERE user_id = ?
-`
-
-type ListLinksRow struct {
-	Url          string         `json:"url"`
-	Title        string         `json:"title"`
-	Note         sql.NullString `json:"note"`
-	BookmarkedAt time.Time      `json:"bookmarked_at"`
-	Tags         sql.NullString `json:"tags"`
+// Moves a link to the trash. Trashed links are no longer pinned.
+func (q *Queries) DeleteLink(ctx context.Context, arg DeleteLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteLink, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) ListLinks(ctx context.Context, userID int64) ([]ListLinksRow, error) {
-	rows, err := q.db.QueryContext(ctx, listLinks, userID)
+const deleteMFAChallenge = `-- name: DeleteMFAChallenge :execrows
+DELETE FROM mfa_challenges
+WHERE id = ?
+`
+
+func (q *Queries) DeleteMFAChallenge(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMFAChallenge, id)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []ListLinksRow
-	for rows.Next() {
-		var i ListLinksRow
-		if err := rows.Scan(
-			&i.Url,
-			&i.Title,
-			&i.Note,
-			&i.BookmarkedAt,
-			&i.Tags,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const listTokens = `-- name: ListTokens :many
-SELECT id, name, short_token FROM tokens
-WHERE user_id = ?
+const deleteOtherTokens = `-- name: DeleteOtherTokens :exec
+DELETE FROM tokens
+WHERE user_id = ? AND id != ?
 `
 
-type ListTokensRow struct {
-	ID         int64  `json:"id"`
-	Name       string `json:"name"`
-	ShortToken string `json:"short_token"`
+type DeleteOtherTokensParams struct {
+	UserID int64 `json:"user_id"`
+	ID     int64 `json:"id"`
 }
 
-func (q *Queries) ListTokens(ctx context.Context, userID int64) ([]ListTokensRow, error) {
-	rows, err := q.db.QueryContext(ctx, listTokens, userID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []ListTokensRow
-	for rows.Next() {
-		var i ListTokensRow
-		if err := rows.Scan(&i.ID, &i.Name, &i.ShortToken); err != nil {
+// Deletes every API token of a user except one. An ID of 0 deletes them all.
+func (q *Queries) DeleteOtherTokens(ctx context.Context, arg DeleteOtherTokensParams) error {
+	_, err := q.db.ExecContext(ctx, deleteOtherTokens, arg.UserID, arg.ID)
+	return err
+}
+
+const deletePasswordResetToken = `-- name: DeletePasswordResetToken :exec
+DELETE FROM password_reset_tokens
+WHERE token_hash = ?
+`
+
+func (q *Queries) DeletePasswordResetToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deletePasswordResetToken, tokenHash)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = ?
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteTag = `-- name: DeleteTag :execrows
+DELETE FROM tags
+WHERE id = ? AND user_id = ?
+`
+
+type DeleteTagParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteTag(ctx context.Context, arg DeleteTagParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTag, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteTemplate = `-- name: DeleteTemplate :execrows
+DELETE FROM templates
+WHERE id = ? AND user_id = ?
+`
+
+type DeleteTemplateParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteTemplate(ctx context.Context, arg DeleteTemplateParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteTemplate, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteToken = `-- name: DeleteToken :exec
+DELETE FROM tokens
+WHERE id = ? AND user_id = ?
+`
+
+type DeleteTokenParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteToken(ctx context.Context, arg DeleteTokenParams) error {
+	_, err := q.db.ExecContext(ctx, deleteToken, arg.ID, arg.UserID)
+	return err
+}
+
+const emptyTrash = `-- name: EmptyTrash :execrows
+DELETE FROM links
+WHERE user_id = ? AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) EmptyTrash(ctx context.Context, userID int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, emptyTrash, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :execrows
+UPDATE users
+SET totp_enabled_at = ?
+WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL
+`
+
+type EnableUserTOTPParams struct {
+	TotpEnabledAt sql.NullTime `json:"totp_enabled_at"`
+	ID            int64        `json:"id"`
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUserTOTP, arg.TotpEnabledAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const exportLinks = `-- name: ExportLinks :many
+SELECT id, url, canonical_url, title, note, tags, bookmarked_at FROM links
+WHERE user_id = ?
+    AND deleted_at IS NULL
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR EXISTS (
+            SELECT 1 FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = links.id AND (
+                t.name = LOWER(CAST(? AS TEXT))
+                OR substr(t.name, 1, length(CAST(? AS TEXT)) + 1) = LOWER(CAST(? AS TEXT)) || '/'
+            )
+        )
+    )
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR domain = CAST(? AS TEXT)
+        OR substr(domain, -length(CAST(? AS TEXT)) - 1) = '.' || CAST(? AS TEXT)
+    )
+    AND (CAST(? AS INTEGER) IS NULL OR unixepoch(bookmarked_at) < CAST(? AS INTEGER))
+    AND (CAST(? AS INTEGER) IS NULL OR unixepoch(bookmarked_at) >= CAST(? AS INTEGER))
+    AND (CAST(? AS BOOLEAN) IS NULL OR (note IS NOT NULL AND note <> '') = CAST(? AS BOOLEAN))
+    AND (CAST(? AS TEXT) IS NULL OR group_id = CAST(? AS TEXT))
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR (CAST(? AS TEXT) = 'unread' AND read_at IS NULL AND archived_at IS NULL)
+        OR (CAST(? AS TEXT) = 'read' AND read_at IS NOT NULL AND archived_at IS NULL)
+        OR (CAST(? AS TEXT) = 'archived' AND archived_at IS NOT NULL)
+    )
+    AND (CAST(? AS BOOLEAN) IS NULL OR starred = CAST(? AS BOOLEAN))
+    AND id > ?
+ORDER BY id
+LIMIT ?
+`
+
+type ExportLinksParams struct {
+	UserID   int64          `json:"user_id"`
+	Tag      sql.NullString `json:"tag"`
+	Domain   sql.NullString `json:"domain"`
+	Before   sql.NullInt64  `json:"before"`
+	After    sql.NullInt64  `json:"after"`
+	HasNote  sql.NullBool   `json:"has_note"`
+	GroupID  sql.NullString `json:"group_id"`
+	State    sql.NullString `json:"state"`
+	Starred  sql.NullBool   `json:"starred"`
+	CursorID int64          `json:"cursor_id"`
+	Limit    int64          `json:"limit"`
+}
+
+type ExportLinksRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	CanonicalUrl sql.NullString `json:"canonical_url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	Tags         sql.NullString `json:"tags"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+}
+
+// Takes the same filters as ListLinksPage. Pages are read in id order, after
+// cursor_id.
+func (q *Queries) ExportLinks(ctx context.Context, arg ExportLinksParams) ([]ExportLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportLinks,
+		arg.UserID,
+		arg.Tag,
+		arg.Tag,
+		arg.Tag,
+		arg.Tag,
+		arg.Domain,
+		arg.Domain,
+		arg.Domain,
+		arg.Domain,
+		arg.Before,
+		arg.Before,
+		arg.After,
+		arg.After,
+		arg.HasNote,
+		arg.HasNote,
+		arg.GroupID,
+		arg.GroupID,
+		arg.State,
+		arg.State,
+		arg.State,
+		arg.State,
+		arg.Starred,
+		arg.Starred,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportLinksRow
+	for rows.Next() {
+		var i ExportLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.CanonicalUrl,
+			&i.Title,
+			&i.Note,
+			&i.Tags,
+			&i.BookmarkedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveSession = `-- name: GetActiveSession :one
+SELECT id, user_id, last_seen_at FROM sessions
+WHERE family_id = ? AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+type GetActiveSessionRow struct {
+	ID         int64        `json:"id"`
+	UserID     int64        `json:"user_id"`
+	LastSeenAt sql.NullTime `json:"last_seen_at"`
+}
+
+// Gets the current refresh token of a session. There is none once the
+// session is revoked.
+func (q *Queries) GetActiveSession(ctx context.Context, familyID string) (GetActiveSessionRow, error) {
+	row := q.db.QueryRowContext(ctx, getActiveSession, familyID)
+	var i GetActiveSessionRow
+	err := row.Scan(&i.ID, &i.UserID, &i.LastSeenAt)
+	return i, err
+}
+
+const getCollection = `-- name: GetCollection :one
+SELECT id, parent_id, name, created_at FROM collections
+WHERE id = ? AND user_id = ?
+`
+
+type GetCollectionParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+type GetCollectionRow struct {
+	ID        int64         `json:"id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (q *Queries) GetCollection(ctx context.Context, arg GetCollectionParams) (GetCollectionRow, error) {
+	row := q.db.QueryRowContext(ctx, getCollection, arg.ID, arg.UserID)
+	var i GetCollectionRow
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getCollectionLink = `-- name: GetCollectionLink :one
+SELECT collection_id, position FROM collection_links
+WHERE link_id = ?
+`
+
+type GetCollectionLinkRow struct {
+	CollectionID int64 `json:"collection_id"`
+	Position     int64 `json:"position"`
+}
+
+func (q *Queries) GetCollectionLink(ctx context.Context, linkID int64) (GetCollectionLinkRow, error) {
+	row := q.db.QueryRowContext(ctx, getCollectionLink, linkID)
+	var i GetCollectionLinkRow
+	err := row.Scan(&i.CollectionID, &i.Position)
+	return i, err
+}
+
+const getHighlight = `-- name: GetHighlight :one
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE id = ? AND link_id = ? AND user_id = ?
+`
+
+type GetHighlightParams struct {
+	ID     int64 `json:"id"`
+	LinkID int64 `json:"link_id"`
+	UserID int64 `json:"user_id"`
+}
+
+type GetHighlightRow struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (q *Queries) GetHighlight(ctx context.Context, arg GetHighlightParams) (GetHighlightRow, error) {
+	row := q.db.QueryRowContext(ctx, getHighlight, arg.ID, arg.LinkID, arg.UserID)
+	var i GetHighlightRow
+	err := row.Scan(
+		&i.ID,
+		&i.LinkID,
+		&i.Quote,
+		&i.Prefix,
+		&i.Suffix,
+		&i.Comment,
+		&i.Color,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getLink = `-- name: GetLink :one
+SELECT id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position FROM links
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+`
+
+type GetLinkParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+type GetLinkRow struct {
+	ID             int64          `json:"id"`
+	Url            string         `json:"url"`
+	Title          string         `json:"title"`
+	Note           sql.NullString `json:"note"`
+	BookmarkedAt   time.Time      `json:"bookmarked_at"`
+	Tags           sql.NullString `json:"tags"`
+	ReadAt         sql.NullTime   `json:"read_at"`
+	ArchivedAt     sql.NullTime   `json:"archived_at"`
+	Starred        bool           `json:"starred"`
+	PinnedPosition sql.NullInt64  `json:"pinned_position"`
+}
+
+func (q *Queries) GetLink(ctx context.Context, arg GetLinkParams) (GetLinkRow, error) {
+	row := q.db.QueryRowContext(ctx, getLink, arg.ID, arg.UserID)
+	var i GetLinkRow
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Title,
+		&i.Note,
+		&i.BookmarkedAt,
+		&i.Tags,
+		&i.ReadAt,
+		&i.ArchivedAt,
+		&i.Starred,
+		&i.PinnedPosition,
+	)
+	return i, err
+}
+
+const getLinkByCanonicalURL = `-- name: GetLinkByCanonicalURL :one
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND canonical_url = ? AND deleted_at IS NULL
+`
+
+type GetLinkByCanonicalURLParams struct {
+	UserID       int64          `json:"user_id"`
+	CanonicalUrl sql.NullString `json:"canonical_url"`
+}
+
+type GetLinkByCanonicalURLRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+}
+
+func (q *Queries) GetLinkByCanonicalURL(ctx context.Context, arg GetLinkByCanonicalURLParams) (GetLinkByCanonicalURLRow, error) {
+	row := q.db.QueryRowContext(ctx, getLinkByCanonicalURL, arg.UserID, arg.CanonicalUrl)
+	var i GetLinkByCanonicalURLRow
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Title,
+		&i.Note,
+		&i.BookmarkedAt,
+		&i.Tags,
+	)
+	return i, err
+}
+
+const getLinkStats = `-- name: GetLinkStats :one
+SELECT
+    COUNT(*) AS total,
+    CAST(COALESCE(SUM(read_at IS NULL AND archived_at IS NULL), 0) AS INTEGER) AS unread,
+    CAST(COALESCE(SUM(read_at IS NOT NULL AND archived_at IS NULL), 0) AS INTEGER) AS read,
+    CAST(COALESCE(SUM(archived_at IS NOT NULL), 0) AS INTEGER) AS archived
+FROM links
+WHERE user_id = ? AND deleted_at IS NULL
+`
+
+type GetLinkStatsRow struct {
+	Total    int64 `json:"total"`
+	Unread   int64 `json:"unread"`
+	Read     int64 `json:"read"`
+	Archived int64 `json:"archived"`
+}
+
+// Unread links are links that are neither read nor archived. Read links don't
+// include archived links.
+func (q *Queries) GetLinkStats(ctx context.Context, userID int64) (GetLinkStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getLinkStats, userID)
+	var i GetLinkStatsRow
+	err := row.Scan(
+		&i.Total,
+		&i.Unread,
+		&i.Read,
+		&i.Archived,
+	)
+	return i, err
+}
+
+const getMFAChallenge = `-- name: GetMFAChallenge :one
+SELECT id, user_id, attempts, expires_at FROM mfa_challenges
+WHERE token_hash = ?
+`
+
+type GetMFAChallengeRow struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Attempts  int64     `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) GetMFAChallenge(ctx context.Context, tokenHash string) (GetMFAChallengeRow, error) {
+	row := q.db.QueryRowContext(ctx, getMFAChallenge, tokenHash)
+	var i GetMFAChallengeRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Attempts,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getPasswordResetToken = `-- name: GetPasswordResetToken :one
+SELECT id, user_id, expires_at, used_at FROM password_reset_tokens
+WHERE token_hash = ?
+`
+
+type GetPasswordResetTokenRow struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+func (q *Queries) GetPasswordResetToken(ctx context.Context, tokenHash string) (GetPasswordResetTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getPasswordResetToken, tokenHash)
+	var i GetPasswordResetTokenRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const getPinnedLinkStats = `-- name: GetPinnedLinkStats :one
+SELECT
+    COUNT(*) AS count,
+    CAST(COALESCE(MAX(pinned_position) + 1, 0) AS INTEGER) AS next_position
+FROM links
+WHERE user_id = ? AND pinned_position IS NOT NULL AND deleted_at IS NULL
+`
+
+type GetPinnedLinkStatsRow struct {
+	Count        int64 `json:"count"`
+	NextPosition int64 `json:"next_position"`
+}
+
+func (q *Queries) GetPinnedLinkStats(ctx context.Context, userID int64) (GetPinnedLinkStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getPinnedLinkStats, userID)
+	var i GetPinnedLinkStatsRow
+	err := row.Scan(&i.Count, &i.NextPosition)
+	return i, err
+}
+
+const getSessionByRefreshTokenHash = `-- name: GetSessionByRefreshTokenHash :one
+SELECT id, user_id, family_id, expires_at, rotated_at, revoked_at FROM sessions
+WHERE refresh_token_hash = ?
+`
+
+type GetSessionByRefreshTokenHashRow struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"user_id"`
+	FamilyID  string       `json:"family_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	RotatedAt sql.NullTime `json:"rotated_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+func (q *Queries) GetSessionByRefreshTokenHash(ctx context.Context, refreshTokenHash string) (GetSessionByRefreshTokenHashRow, error) {
+	row := q.db.QueryRowContext(ctx, getSessionByRefreshTokenHash, refreshTokenHash)
+	var i GetSessionByRefreshTokenHashRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.ExpiresAt,
+		&i.RotatedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getTag = `-- name: GetTag :one
+SELECT id, name FROM tags
+WHERE id = ? AND user_id = ?
+`
+
+type GetTagParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+type GetTagRow struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (q *Queries) GetTag(ctx context.Context, arg GetTagParams) (GetTagRow, error) {
+	row := q.db.QueryRowContext(ctx, getTag, arg.ID, arg.UserID)
+	var i GetTagRow
+	err := row.Scan(&i.ID, &i.Name)
+	return i, err
+}
+
+const getTagByName = `-- name: GetTagByName :one
+SELECT id, name FROM tags
+WHERE user_id = ? AND name = ?
+`
+
+type GetTagByNameParams struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type GetTagByNameRow struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (q *Queries) GetTagByName(ctx context.Context, arg GetTagByNameParams) (GetTagByNameRow, error) {
+	row := q.db.QueryRowContext(ctx, getTagByName, arg.UserID, arg.Name)
+	var i GetTagByNameRow
+	err := row.Scan(&i.ID, &i.Name)
+	return i, err
+}
+
+const getTemplate = `-- name: GetTemplate :one
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE id = ? AND user_id = ?
+`
+
+type GetTemplateParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+type GetTemplateRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) GetTemplate(ctx context.Context, arg GetTemplateParams) (GetTemplateRow, error) {
+	row := q.db.QueryRowContext(ctx, getTemplate, arg.ID, arg.UserID)
+	var i GetTemplateRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Body,
+		&i.PerLink,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTemplateByName = `-- name: GetTemplateByName :one
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE user_id = ? AND name = ?
+`
+
+type GetTemplateByNameParams struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type GetTemplateByNameRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) GetTemplateByName(ctx context.Context, arg GetTemplateByNameParams) (GetTemplateByNameRow, error) {
+	row := q.db.QueryRowContext(ctx, getTemplateByName, arg.UserID, arg.Name)
+	var i GetTemplateByNameRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Body,
+		&i.PerLink,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getToken = `-- name: GetToken :one
+SELECT id, name, user_id FROM tokens
+WHERE token_hash = ?
+`
+
+type GetTokenRow struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	UserID int64  `json:"user_id"`
+}
+
+func (q *Queries) GetToken(ctx context.Context, tokenHash string) (GetTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getToken, tokenHash)
+	var i GetTokenRow
+	err := row.Scan(&i.ID, &i.Name, &i.UserID)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
+WHERE username = ?
+`
+
+type GetUserRow struct {
+	ID              int64          `json:"id"`
+	Username        string         `json:"username"`
+	Password        string         `json:"password"`
+	Email           sql.NullString `json:"email"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	TotpEnabledAt   sql.NullTime   `json:"totp_enabled_at"`
+}
+
+func (q *Queries) GetUser(ctx context.Context, username string) (GetUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getUser, username)
+	var i GetUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.TotpEnabledAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
+WHERE email = ? COLLATE NOCASE AND email_verified_at IS NOT NULL
+`
+
+type GetUserByEmailRow struct {
+	ID              int64          `json:"id"`
+	Username        string         `json:"username"`
+	Password        string         `json:"password"`
+	Email           sql.NullString `json:"email"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	TotpEnabledAt   sql.NullTime   `json:"totp_enabled_at"`
+}
+
+// Only verified email addresses can be used to sign in.
+func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (GetUserByEmailRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i GetUserByEmailRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.TotpEnabledAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, username, password, email, email_verified_at, totp_enabled_at FROM users
+WHERE id = ?
+`
+
+type GetUserByIDRow struct {
+	ID              int64          `json:"id"`
+	Username        string         `json:"username"`
+	Password        string         `json:"password"`
+	Email           sql.NullString `json:"email"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	TotpEnabledAt   sql.NullTime   `json:"totp_enabled_at"`
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id int64) (GetUserByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i GetUserByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Password,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		&i.TotpEnabledAt,
+	)
+	return i, err
+}
+
+const getUserTOTP = `-- name: GetUserTOTP :one
+SELECT totp_secret, totp_enabled_at, mfa_locked_until FROM users
+WHERE id = ?
+`
+
+type GetUserTOTPRow struct {
+	TotpSecret     sql.NullString `json:"totp_secret"`
+	TotpEnabledAt  sql.NullTime   `json:"totp_enabled_at"`
+	MfaLockedUntil sql.NullTime   `json:"mfa_locked_until"`
+}
+
+func (q *Queries) GetUserTOTP(ctx context.Context, id int64) (GetUserTOTPRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserTOTP, id)
+	var i GetUserTOTPRow
+	err := row.Scan(&i.TotpSecret, &i.TotpEnabledAt, &i.MfaLockedUntil)
+	return i, err
+}
+
+const incrementMFAChallengeAttempts = `-- name: IncrementMFAChallengeAttempts :exec
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE id = ?
+`
+
+func (q *Queries) IncrementMFAChallengeAttempts(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, incrementMFAChallengeAttempts, id)
+	return err
+}
+
+const invalidatePasswordResetTokens = `-- name: InvalidatePasswordResetTokens :execrows
+UPDATE password_reset_tokens
+SET used_at = ?
+WHERE user_id = ? AND used_at IS NULL
+`
+
+type InvalidatePasswordResetTokensParams struct {
+	UsedAt sql.NullTime `json:"used_at"`
+	UserID int64        `json:"user_id"`
+}
+
+// Uses up every outstanding reset token of a user, so that only one of them
+// can ever reset the password.
+func (q *Queries) InvalidatePasswordResetTokens(ctx context.Context, arg InvalidatePasswordResetTokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, invalidatePasswordResetTokens, arg.UsedAt, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listCollectionLinks = `-- name: ListCollectionLinks :many
+SELECT l.id, l.url, l.title, l.note, l.bookmarked_at, l.tags, cl.position FROM collection_links cl
+JOIN links l ON l.id = cl.link_id
+WHERE cl.collection_id = ? AND l.user_id = ? AND l.deleted_at IS NULL
+ORDER BY cl.position, l.id
+`
+
+type ListCollectionLinksParams struct {
+	CollectionID int64 `json:"collection_id"`
+	UserID       int64 `json:"user_id"`
+}
+
+type ListCollectionLinksRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+	Position     int64          `json:"position"`
+}
+
+func (q *Queries) ListCollectionLinks(ctx context.Context, arg ListCollectionLinksParams) ([]ListCollectionLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCollectionLinks, arg.CollectionID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCollectionLinksRow
+	for rows.Next() {
+		var i ListCollectionLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCollections = `-- name: ListCollections :many
+SELECT
+    c.id,
+    c.parent_id,
+    c.name,
+    c.created_at,
+    COUNT(l.id) AS link_count
+FROM collections c
+LEFT JOIN collection_links cl ON cl.collection_id = c.id
+LEFT JOIN links l ON l.id = cl.link_id AND l.deleted_at IS NULL
+WHERE c.user_id = ?
+GROUP BY c.id
+ORDER BY c.name
+`
+
+type ListCollectionsRow struct {
+	ID        int64         `json:"id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+	LinkCount int64         `json:"link_count"`
+}
+
+func (q *Queries) ListCollections(ctx context.Context, userID int64) ([]ListCollectionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listCollections, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCollectionsRow
+	for rows.Next() {
+		var i ListCollectionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.LinkCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHighlights = `-- name: ListHighlights :many
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE link_id = ? AND user_id = ?
+ORDER BY id
+`
+
+type ListHighlightsParams struct {
+	LinkID int64 `json:"link_id"`
+	UserID int64 `json:"user_id"`
+}
+
+type ListHighlightsRow struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (q *Queries) ListHighlights(ctx context.Context, arg ListHighlightsParams) ([]ListHighlightsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listHighlights, arg.LinkID, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListHighlightsRow
+	for rows.Next() {
+		var i ListHighlightsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkID,
+			&i.Quote,
+			&i.Prefix,
+			&i.Suffix,
+			&i.Comment,
+			&i.Color,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHighlightsByLinkIDs = `-- name: ListHighlightsByLinkIDs :many
+SELECT id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at FROM highlights
+WHERE user_id = ? AND link_id IN (/*SLICE:link_ids*/?)
+ORDER BY link_id, id
+`
+
+type ListHighlightsByLinkIDsParams struct {
+	UserID  int64   `json:"user_id"`
+	LinkIds []int64 `json:"link_ids"`
+}
+
+type ListHighlightsByLinkIDsRow struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (q *Queries) ListHighlightsByLinkIDs(ctx context.Context, arg ListHighlightsByLinkIDsParams) ([]ListHighlightsByLinkIDsRow, error) {
+	query := listHighlightsByLinkIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.LinkIds) > 0 {
+		for _, v := range arg.LinkIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:link_ids*/?", strings.Repeat(",?", len(arg.LinkIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:link_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListHighlightsByLinkIDsRow
+	for rows.Next() {
+		var i ListHighlightsByLinkIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkID,
+			&i.Quote,
+			&i.Prefix,
+			&i.Suffix,
+			&i.Comment,
+			&i.Color,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinks = `-- name: ListLinks :many
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND deleted_at IS NULL
+ORDER BY pinned_position IS NULL, pinned_position, id
+`
+
+type ListLinksRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+}
+
+// Pinned links come first.
+func (q *Queries) ListLinks(ctx context.Context, userID int64) ([]ListLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLinks, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksRow
+	for rows.Next() {
+		var i ListLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksByFolder = `-- name: ListLinksByFolder :many
+SELECT id, url, title, note, bookmarked_at, tags, folder_key FROM (
+    SELECT id, url, title, note, bookmarked_at, tags,
+        CAST(replace(substr(COALESCE(tags, ''), 1, instr(COALESCE(tags, '') || ',', ',') - 1), '/', char(1)) AS TEXT) AS folder_key
+    FROM links
+    WHERE user_id = ? AND deleted_at IS NULL
+)
+WHERE folder_key > ?
+    OR (folder_key = ? AND id > ?)
+ORDER BY folder_key, id
+LIMIT ?
+`
+
+type ListLinksByFolderParams struct {
+	UserID    int64  `json:"user_id"`
+	CursorKey string `json:"cursor_key"`
+	CursorID  int64  `json:"cursor_id"`
+	Limit     int64  `json:"limit"`
+}
+
+type ListLinksByFolderRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+	FolderKey    string         `json:"folder_key"`
+}
+
+// Orders links by their first tag so that each folder of an export, nested
+// folders included, is contiguous. Slashes sort before any other character in
+// folder_key for that reason.
+func (q *Queries) ListLinksByFolder(ctx context.Context, arg ListLinksByFolderParams) ([]ListLinksByFolderRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLinksByFolder,
+		arg.UserID,
+		arg.CursorKey,
+		arg.CursorKey,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksByFolderRow
+	for rows.Next() {
+		var i ListLinksByFolderRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+			&i.FolderKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksPage = `-- name: ListLinksPage :many
+SELECT id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position FROM links
+WHERE user_id = ?
+    AND deleted_at IS NULL
+    AND (
+        CAST(? AS INTEGER) IS NULL
+        OR unixepoch(bookmarked_at) < CAST(? AS INTEGER)
+        OR (unixepoch(bookmarked_at) = CAST(? AS INTEGER) AND id < CAST(? AS INTEGER))
+    )
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR EXISTS (
+            SELECT 1 FROM link_tags lt
+            JOIN tags t ON t.id = lt.tag_id
+            WHERE lt.link_id = links.id AND (
+                t.name = LOWER(CAST(? AS TEXT))
+                OR substr(t.name, 1, length(CAST(? AS TEXT)) + 1) = LOWER(CAST(? AS TEXT)) || '/'
+            )
+        )
+    )
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR domain = CAST(? AS TEXT)
+        OR substr(domain, -length(CAST(? AS TEXT)) - 1) = '.' || CAST(? AS TEXT)
+    )
+    AND (CAST(? AS INTEGER) IS NULL OR unixepoch(bookmarked_at) < CAST(? AS INTEGER))
+    AND (CAST(? AS INTEGER) IS NULL OR unixepoch(bookmarked_at) >= CAST(? AS INTEGER))
+    AND (CAST(? AS BOOLEAN) IS NULL OR (note IS NOT NULL AND note <> '') = CAST(? AS BOOLEAN))
+    AND (CAST(? AS TEXT) IS NULL OR group_id = CAST(? AS TEXT))
+    AND (
+        CAST(? AS TEXT) IS NULL
+        OR (CAST(? AS TEXT) = 'unread' AND read_at IS NULL AND archived_at IS NULL)
+        OR (CAST(? AS TEXT) = 'read' AND read_at IS NOT NULL AND archived_at IS NULL)
+        OR (CAST(? AS TEXT) = 'archived' AND archived_at IS NOT NULL)
+    )
+    AND (CAST(? AS BOOLEAN) IS NULL OR starred = CAST(? AS BOOLEAN))
+    AND (CAST(? AS BOOLEAN) IS NULL OR (pinned_position IS NOT NULL) = CAST(? AS BOOLEAN))
+ORDER BY unixepoch(bookmarked_at) DESC, id DESC
+LIMIT ?
+`
+
+type ListLinksPageParams struct {
+	UserID     int64          `json:"user_id"`
+	CursorTime sql.NullInt64  `json:"cursor_time"`
+	CursorID   sql.NullInt64  `json:"cursor_id"`
+	Tag        sql.NullString `json:"tag"`
+	Domain     sql.NullString `json:"domain"`
+	Before     sql.NullInt64  `json:"before"`
+	After      sql.NullInt64  `json:"after"`
+	HasNote    sql.NullBool   `json:"has_note"`
+	GroupID    sql.NullString `json:"group_id"`
+	State      sql.NullString `json:"state"`
+	Starred    sql.NullBool   `json:"starred"`
+	Pinned     sql.NullBool   `json:"pinned"`
+	Limit      int64          `json:"limit"`
+}
+
+type ListLinksPageRow struct {
+	ID             int64          `json:"id"`
+	Url            string         `json:"url"`
+	Title          string         `json:"title"`
+	Note           sql.NullString `json:"note"`
+	BookmarkedAt   time.Time      `json:"bookmarked_at"`
+	Tags           sql.NullString `json:"tags"`
+	ReadAt         sql.NullTime   `json:"read_at"`
+	ArchivedAt     sql.NullTime   `json:"archived_at"`
+	Starred        bool           `json:"starred"`
+	PinnedPosition sql.NullInt64  `json:"pinned_position"`
+}
+
+func (q *Queries) ListLinksPage(ctx context.Context, arg ListLinksPageParams) ([]ListLinksPageRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLinksPage,
+		arg.UserID,
+		arg.CursorTime,
+		arg.CursorTime,
+		arg.CursorTime,
+		arg.CursorID,
+		arg.Tag,
+		arg.Tag,
+		arg.Tag,
+		arg.Tag,
+		arg.Domain,
+		arg.Domain,
+		arg.Domain,
+		arg.Domain,
+		arg.Before,
+		arg.Before,
+		arg.After,
+		arg.After,
+		arg.HasNote,
+		arg.HasNote,
+		arg.GroupID,
+		arg.GroupID,
+		arg.State,
+		arg.State,
+		arg.State,
+		arg.State,
+		arg.Starred,
+		arg.Starred,
+		arg.Pinned,
+		arg.Pinned,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksPageRow
+	for rows.Next() {
+		var i ListLinksPageRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+			&i.ReadAt,
+			&i.ArchivedAt,
+			&i.Starred,
+			&i.PinnedPosition,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksSince = `-- name: ListLinksSince :many
+SELECT id, url, title, note, bookmarked_at, tags FROM links
+WHERE user_id = ? AND id > ? AND deleted_at IS NULL
+ORDER BY id
+LIMIT ?
+`
+
+type ListLinksSinceParams struct {
+	UserID int64 `json:"user_id"`
+	Since  int64 `json:"since"`
+	Limit  int64 `json:"limit"`
+}
+
+type ListLinksSinceRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+}
+
+func (q *Queries) ListLinksSince(ctx context.Context, arg ListLinksSinceParams) ([]ListLinksSinceRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLinksSince, arg.UserID, arg.Since, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksSinceRow
+	for rows.Next() {
+		var i ListLinksSinceRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLinksWithoutCanonicalURL = `-- name: ListLinksWithoutCanonicalURL :many
+SELECT id, url FROM links
+WHERE canonical_url IS NULL AND NOT canonical_url_conflict
+ORDER BY id
+`
+
+type ListLinksWithoutCanonicalURLRow struct {
+	ID  int64  `json:"id"`
+	Url string `json:"url"`
+}
+
+func (q *Queries) ListLinksWithoutCanonicalURL(ctx context.Context) ([]ListLinksWithoutCanonicalURLRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLinksWithoutCanonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListLinksWithoutCanonicalURLRow
+	for rows.Next() {
+		var i ListLinksWithoutCanonicalURLRow
+		if err := rows.Scan(&i.ID, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSessions = `-- name: ListSessions :many
+SELECT family_id, user_agent, ip_address, last_seen_at, expires_at FROM sessions
+WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND unixepoch(expires_at) > CAST(? AS INTEGER)
+ORDER BY unixepoch(last_seen_at) DESC, id DESC
+`
+
+type ListSessionsParams struct {
+	UserID int64 `json:"user_id"`
+	Now    int64 `json:"now"`
+}
+
+type ListSessionsRow struct {
+	FamilyID   string         `json:"family_id"`
+	UserAgent  sql.NullString `json:"user_agent"`
+	IpAddress  sql.NullString `json:"ip_address"`
+	LastSeenAt sql.NullTime   `json:"last_seen_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+func (q *Queries) ListSessions(ctx context.Context, arg ListSessionsParams) ([]ListSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSessions, arg.UserID, arg.Now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSessionsRow
+	for rows.Next() {
+		var i ListSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastSeenAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagDescendants = `-- name: ListTagDescendants :many
+SELECT id, name FROM tags
+WHERE user_id = ? AND (
+    name = ? OR substr(name, 1, length(?) + 1) = ? || '/'
+)
+ORDER BY name
+`
+
+type ListTagDescendantsParams struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+type ListTagDescendantsRow struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (q *Queries) ListTagDescendants(ctx context.Context, arg ListTagDescendantsParams) ([]ListTagDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTagDescendants,
+		arg.UserID,
+		arg.Name,
+		arg.Name,
+		arg.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagDescendantsRow
+	for rows.Next() {
+		var i ListTagDescendantsRow
+		if err := rows.Scan(&i.ID, &i.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagLinks = `-- name: ListTagLinks :many
+SELECT t.name, lt.link_id FROM tags t
+JOIN link_tags lt ON lt.tag_id = t.id
+JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+WHERE t.user_id = ?
+`
+
+type ListTagLinksRow struct {
+	Name   string `json:"name"`
+	LinkID int64  `json:"link_id"`
+}
+
+func (q *Queries) ListTagLinks(ctx context.Context, userID int64) ([]ListTagLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTagLinks, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagLinksRow
+	for rows.Next() {
+		var i ListTagLinksRow
+		if err := rows.Scan(&i.Name, &i.LinkID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagsWithCounts = `-- name: ListTagsWithCounts :many
+SELECT
+    t.id,
+    t.name,
+    COUNT(l.id) AS link_count,
+    CAST(MAX(unixepoch(l.bookmarked_at)) AS INTEGER) AS last_used_at
+FROM tags t
+LEFT JOIN link_tags lt ON lt.tag_id = t.id
+LEFT JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+WHERE t.user_id = ?
+GROUP BY t.id
+ORDER BY t.name
+`
+
+type ListTagsWithCountsRow struct {
+	ID         int64         `json:"id"`
+	Name       string        `json:"name"`
+	LinkCount  int64         `json:"link_count"`
+	LastUsedAt sql.NullInt64 `json:"last_used_at"`
+}
+
+func (q *Queries) ListTagsWithCounts(ctx context.Context, userID int64) ([]ListTagsWithCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTagsWithCounts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTagsWithCountsRow
+	for rows.Next() {
+		var i ListTagsWithCountsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.LinkCount,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTemplates = `-- name: ListTemplates :many
+SELECT id, name, body, per_link, created_at, updated_at FROM templates
+WHERE user_id = ?
+ORDER BY name
+`
+
+type ListTemplatesRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) ListTemplates(ctx context.Context, userID int64) ([]ListTemplatesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTemplates, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTemplatesRow
+	for rows.Next() {
+		var i ListTemplatesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Body,
+			&i.PerLink,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTokens = `-- name: ListTokens :many
+SELECT id, name, short_token FROM tokens
+WHERE user_id = ?
+`
+
+type ListTokensRow struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	ShortToken string `json:"short_token"`
+}
+
+func (q *Queries) ListTokens(ctx context.Context, userID int64) ([]ListTokensRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTokensRow
+	for rows.Next() {
+		var i ListTokensRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.ShortToken); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTrash = `-- name: ListTrash :many
+SELECT id, url, title, note, bookmarked_at, tags, deleted_at FROM links
+WHERE user_id = ? AND deleted_at IS NOT NULL
+ORDER BY unixepoch(deleted_at) DESC, id DESC
+LIMIT ? OFFSET ?
+`
+
+type ListTrashParams struct {
+	UserID int64 `json:"user_id"`
+	Limit  int64 `json:"limit"`
+	Offset int64 `json:"offset"`
+}
+
+type ListTrashRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+	DeletedAt    sql.NullTime   `json:"deleted_at"`
+}
+
+func (q *Queries) ListTrash(ctx context.Context, arg ListTrashParams) ([]ListTrashRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrash, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTrashRow
+	for rows.Next() {
+		var i ListTrashRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockMFA = `-- name: LockMFA :exec
+UPDATE users
+SET mfa_locked_until = ?
+WHERE id = ?
+`
+
+type LockMFAParams struct {
+	MfaLockedUntil sql.NullTime `json:"mfa_locked_until"`
+	ID             int64        `json:"id"`
+}
+
+func (q *Queries) LockMFA(ctx context.Context, arg LockMFAParams) error {
+	_, err := q.db.ExecContext(ctx, lockMFA, arg.MfaLockedUntil, arg.ID)
+	return err
+}
+
+const markLinkCanonicalURLConflict = `-- name: MarkLinkCanonicalURLConflict :exec
+UPDATE links
+SET canonical_url_conflict = 1
+WHERE id = ?
+`
+
+func (q *Queries) MarkLinkCanonicalURLConflict(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markLinkCanonicalURLConflict, id)
+	return err
+}
+
+const markLinksArchived = `-- name: MarkLinksArchived :execrows
+UPDATE links
+SET archived_at = COALESCE(archived_at, ?)
+WHERE user_id = ? AND deleted_at IS NULL AND id IN (/*SLICE:ids*/?)
+`
+
+type MarkLinksArchivedParams struct {
+	ArchivedAt sql.NullTime `json:"archived_at"`
+	UserID     int64        `json:"user_id"`
+	Ids        []int64      `json:"ids"`
+}
+
+func (q *Queries) MarkLinksArchived(ctx context.Context, arg MarkLinksArchivedParams) (int64, error) {
+	query := markLinksArchived
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ArchivedAt)
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markLinksRead = `-- name: MarkLinksRead :execrows
+UPDATE links
+SET read_at = COALESCE(read_at, ?), archived_at = NULL
+WHERE user_id = ? AND deleted_at IS NULL AND id IN (/*SLICE:ids*/?)
+`
+
+type MarkLinksReadParams struct {
+	ReadAt sql.NullTime `json:"read_at"`
+	UserID int64        `json:"user_id"`
+	Ids    []int64      `json:"ids"`
+}
+
+// Links that were already read keep their read_at. Archived links are moved
+// back to the reading list.
+func (q *Queries) MarkLinksRead(ctx context.Context, arg MarkLinksReadParams) (int64, error) {
+	query := markLinksRead
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ReadAt)
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markLinksUnread = `-- name: MarkLinksUnread :execrows
+UPDATE links
+SET read_at = NULL, archived_at = NULL
+WHERE user_id = ? AND deleted_at IS NULL AND id IN (/*SLICE:ids*/?)
+`
+
+type MarkLinksUnreadParams struct {
+	UserID int64   `json:"user_id"`
+	Ids    []int64 `json:"ids"`
+}
+
+func (q *Queries) MarkLinksUnread(ctx context.Context, arg MarkLinksUnreadParams) (int64, error) {
+	query := markLinksUnread
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.UserID)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markTokenDelivered = `-- name: MarkTokenDelivered :exec
+UPDATE tokens
+SET sync_delivered = MAX(sync_delivered, ?)
+WHERE id = ? AND user_id = ?
+`
+
+type MarkTokenDeliveredParams struct {
+	SyncDelivered int64 `json:"sync_delivered"`
+	ID            int64 `json:"id"`
+	UserID        int64 `json:"user_id"`
+}
+
+func (q *Queries) MarkTokenDelivered(ctx context.Context, arg MarkTokenDeliveredParams) error {
+	_, err := q.db.ExecContext(ctx, markTokenDelivered, arg.SyncDelivered, arg.ID, arg.UserID)
+	return err
+}
+
+const moveLinkTags = `-- name: MoveLinkTags :exec
+INSERT OR IGNORE INTO link_tags (link_id, tag_id)
+SELECT link_id, ? FROM link_tags
+WHERE tag_id = ?
+`
+
+type MoveLinkTagsParams struct {
+	TargetTagID int64 `json:"target_tag_id"`
+	SourceTagID int64 `json:"source_tag_id"`
+}
+
+func (q *Queries) MoveLinkTags(ctx context.Context, arg MoveLinkTagsParams) error {
+	_, err := q.db.ExecContext(ctx, moveLinkTags, arg.TargetTagID, arg.SourceTagID)
+	return err
+}
+
+const pruneSyncedLinks = `-- name: PruneSyncedLinks :execrows
+UPDATE links
+SET deleted_at = CURRENT_TIMESTAMP, consumed_at = CURRENT_TIMESTAMP, pinned_position = NULL
+WHERE links.user_id = ? AND links.deleted_at IS NULL AND links.consumed_at IS NULL AND (
+    links.id <= (
+        SELECT COALESCE(MIN(t.sync_cursor), 0) FROM tokens t
+        WHERE t.user_id = ?
+    )
+    OR (
+        links.id <= (
+            SELECT COALESCE(MAX(t.sync_cursor), 0) FROM tokens t
+            WHERE t.user_id = ?
+        )
+        AND links.bookmarked_at < ?
+    )
+)
+`
+
+type PruneSyncedLinksParams struct {
+	UserID          int64     `json:"user_id"`
+	RetentionCutoff time.Time `json:"retention_cutoff"`
+}
+
+// Moves links the sync consumers are done with to the trash, where they can
+// still be restored.
+func (q *Queries) PruneSyncedLinks(ctx context.Context, arg PruneSyncedLinksParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneSyncedLinks,
+		arg.UserID,
+		arg.UserID,
+		arg.UserID,
+		arg.RetentionCutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeDeletedLinks = `-- name: PurgeDeletedLinks :execrows
+DELETE FROM links
+WHERE deleted_at IS NOT NULL AND unixepoch(deleted_at) < CAST(? AS INTEGER)
+`
+
+// Permanently deletes the links of all users that have been in the trash
+// since before the cutoff.
+func (q *Queries) PurgeDeletedLinks(ctx context.Context, cutoff int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedLinks, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeExpiredMFAChallenges = `-- name: PurgeExpiredMFAChallenges :execrows
+DELETE FROM mfa_challenges
+WHERE unixepoch(expires_at) < CAST(? AS INTEGER)
+`
+
+func (q *Queries) PurgeExpiredMFAChallenges(ctx context.Context, cutoff int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeExpiredMFAChallenges, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeExpiredPasswordResetTokens = `-- name: PurgeExpiredPasswordResetTokens :execrows
+DELETE FROM password_reset_tokens
+WHERE unixepoch(expires_at) < CAST(? AS INTEGER)
+`
+
+func (q *Queries) PurgeExpiredPasswordResetTokens(ctx context.Context, cutoff int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeExpiredPasswordResetTokens, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeExpiredSessions = `-- name: PurgeExpiredSessions :execrows
+DELETE FROM sessions
+WHERE unixepoch(expires_at) < CAST(? AS INTEGER)
+`
+
+func (q *Queries) PurgeExpiredSessions(ctx context.Context, cutoff int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeExpiredSessions, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeLink = `-- name: PurgeLink :execrows
+DELETE FROM links
+WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
+`
+
+type PurgeLinkParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+func (q *Queries) PurgeLink(ctx context.Context, arg PurgeLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeLink, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordMFAFailure = `-- name: RecordMFAFailure :one
+UPDATE users
+SET mfa_failed_attempts = mfa_failed_attempts + 1
+WHERE id = ?
+RETURNING mfa_failed_attempts
+`
+
+// Counts a wrong second factor code, and returns how many there were in a
+// row.
+func (q *Queries) RecordMFAFailure(ctx context.Context, id int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, recordMFAFailure, id)
+	var mfa_failed_attempts int64
+	err := row.Scan(&mfa_failed_attempts)
+	return mfa_failed_attempts, err
+}
+
+const removeCollectionLink = `-- name: RemoveCollectionLink :exec
+DELETE FROM collection_links
+WHERE link_id = ?
+`
+
+func (q *Queries) RemoveCollectionLink(ctx context.Context, linkID int64) error {
+	_, err := q.db.ExecContext(ctx, removeCollectionLink, linkID)
+	return err
+}
+
+const renameTag = `-- name: RenameTag :exec
+UPDATE tags
+SET name = ?
+WHERE id = ? AND user_id = ?
+`
+
+type RenameTagParams struct {
+	Name   string `json:"name"`
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+}
+
+func (q *Queries) RenameTag(ctx context.Context, arg RenameTagParams) error {
+	_, err := q.db.ExecContext(ctx, renameTag, arg.Name, arg.ID, arg.UserID)
+	return err
+}
+
+const resetMFAFailures = `-- name: ResetMFAFailures :exec
+UPDATE users
+SET mfa_failed_attempts = 0, mfa_locked_until = NULL
+WHERE id = ? AND (mfa_failed_attempts > 0 OR mfa_locked_until IS NOT NULL)
+`
+
+func (q *Queries) ResetMFAFailures(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, resetMFAFailures, id)
+	return err
+}
+
+const restoreLink = `-- name: RestoreLink :execrows
+UPDATE links
+SET deleted_at = NULL, lease_id = NULL, lease_expires_at = NULL
+WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL
+`
+
+type RestoreLinkParams struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+}
+
+// Restored links keep consumed_at, so that sync consumers that were done with
+// them don't claim or prune them again.
+func (q *Queries) RestoreLink(ctx context.Context, arg RestoreLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreLink, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeOtherSessions = `-- name: RevokeOtherSessions :exec
+UPDATE sessions
+SET revoked_at = ?
+WHERE user_id = ? AND family_id != ? AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsParams struct {
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	UserID    int64        `json:"user_id"`
+	FamilyID  string       `json:"family_id"`
+}
+
+// Revokes every session of a user except one. An empty family ID revokes
+// them all.
+func (q *Queries) RevokeOtherSessions(ctx context.Context, arg RevokeOtherSessionsParams) error {
+	_, err := q.db.ExecContext(ctx, revokeOtherSessions, arg.RevokedAt, arg.UserID, arg.FamilyID)
+	return err
+}
+
+const revokeSessionFamily = `-- name: RevokeSessionFamily :execrows
+UPDATE sessions
+SET revoked_at = ?
+WHERE family_id = ? AND user_id = ? AND revoked_at IS NULL
+`
+
+type RevokeSessionFamilyParams struct {
+	RevokedAt sql.NullTime `json:"revoked_at"`
+	FamilyID  string       `json:"family_id"`
+	UserID    int64        `json:"user_id"`
+}
+
+func (q *Queries) RevokeSessionFamily(ctx context.Context, arg RevokeSessionFamilyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeSessionFamily, arg.RevokedAt, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateSession = `-- name: RotateSession :execrows
+UPDATE sessions
+SET rotated_at = ?
+WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL
+`
+
+type RotateSessionParams struct {
+	RotatedAt sql.NullTime `json:"rotated_at"`
+	ID        int64        `json:"id"`
+}
+
+// Marks a refresh token as exchanged. Zero rows are affected if it already
+// was, which means the token is being reused.
+func (q *Queries) RotateSession(ctx context.Context, arg RotateSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateSession, arg.RotatedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchLinks = `-- name: SearchLinks :many
+SELECT
+    l.id, l.url, l.title, l.note, l.bookmarked_at, l.tags,
+    CAST(COALESCE(snippet(links_fts, 0, '<mark>', '</mark>', '…', 12), '') AS TEXT) AS title_snippet,
+    CAST(COALESCE(snippet(links_fts, 1, '<mark>', '</mark>', '…', 24), '') AS TEXT) AS note_snippet,
+    CAST(bm25(links_fts, 10.0, 4.0, 2.0, 6.0) AS REAL) AS rank
+FROM links_fts
+JOIN links l ON l.id = links_fts.rowid
+WHERE links_fts MATCH CAST(? AS TEXT) AND l.user_id = ? AND l.deleted_at IS NULL
+ORDER BY rank
+LIMIT ? OFFSET ?
+`
+
+type SearchLinksParams struct {
+	Query  string `json:"query"`
+	UserID int64  `json:"user_id"`
+	Limit  int64  `json:"limit"`
+	Offset int64  `json:"offset"`
+}
+
+type SearchLinksRow struct {
+	ID           int64          `json:"id"`
+	Url          string         `json:"url"`
+	Title        string         `json:"title"`
+	Note         sql.NullString `json:"note"`
+	BookmarkedAt time.Time      `json:"bookmarked_at"`
+	Tags         sql.NullString `json:"tags"`
+	TitleSnippet string         `json:"title_snippet"`
+	NoteSnippet  string         `json:"note_snippet"`
+	Rank         float64        `json:"rank"`
+}
+
+func (q *Queries) SearchLinks(ctx context.Context, arg SearchLinksParams) ([]SearchLinksRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchLinks,
+		arg.Query,
+		arg.UserID,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchLinksRow
+	for rows.Next() {
+		var i SearchLinksRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Title,
+			&i.Note,
+			&i.BookmarkedAt,
+			&i.Tags,
+			&i.TitleSnippet,
+			&i.NoteSnippet,
+			&i.Rank,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -218,3 +2748,518 @@ func (q *Queries) ListTokens(ctx context.Context, userID int64) ([]ListTokensRow
 	}
 	return items, nil
 }
+
+const setEmailVerificationNonce = `-- name: SetEmailVerificationNonce :exec
+UPDATE users
+SET email_verification_nonce = ?
+WHERE id = ?
+`
+
+type SetEmailVerificationNonceParams struct {
+	EmailVerificationNonce sql.NullString `json:"email_verification_nonce"`
+	ID                     int64          `json:"id"`
+}
+
+func (q *Queries) SetEmailVerificationNonce(ctx context.Context, arg SetEmailVerificationNonceParams) error {
+	_, err := q.db.ExecContext(ctx, setEmailVerificationNonce, arg.EmailVerificationNonce, arg.ID)
+	return err
+}
+
+const setLinkBookmarkedAt = `-- name: SetLinkBookmarkedAt :exec
+UPDATE links
+SET bookmarked_at = ?
+WHERE id = ? AND user_id = ?
+`
+
+type SetLinkBookmarkedAtParams struct {
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+}
+
+func (q *Queries) SetLinkBookmarkedAt(ctx context.Context, arg SetLinkBookmarkedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setLinkBookmarkedAt, arg.BookmarkedAt, arg.ID, arg.UserID)
+	return err
+}
+
+const setLinkCanonicalURL = `-- name: SetLinkCanonicalURL :execrows
+UPDATE OR IGNORE links
+SET canonical_url = ?
+WHERE id = ?
+`
+
+type SetLinkCanonicalURLParams struct {
+	CanonicalUrl sql.NullString `json:"canonical_url"`
+	ID           int64          `json:"id"`
+}
+
+// Duplicates of a link that already has the canonical URL are left without one.
+func (q *Queries) SetLinkCanonicalURL(ctx context.Context, arg SetLinkCanonicalURLParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setLinkCanonicalURL, arg.CanonicalUrl, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setLinkPinnedPosition = `-- name: SetLinkPinnedPosition :execrows
+UPDATE links
+SET pinned_position = ?
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+`
+
+type SetLinkPinnedPositionParams struct {
+	PinnedPosition sql.NullInt64 `json:"pinned_position"`
+	ID             int64         `json:"id"`
+	UserID         int64         `json:"user_id"`
+}
+
+// A NULL position unpins the link.
+func (q *Queries) SetLinkPinnedPosition(ctx context.Context, arg SetLinkPinnedPositionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setLinkPinnedPosition, arg.PinnedPosition, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setLinkStarred = `-- name: SetLinkStarred :execrows
+UPDATE links
+SET starred = ?
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+`
+
+type SetLinkStarredParams struct {
+	Starred bool  `json:"starred"`
+	ID      int64 `json:"id"`
+	UserID  int64 `json:"user_id"`
+}
+
+func (q *Queries) SetLinkStarred(ctx context.Context, arg SetLinkStarredParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setLinkStarred, arg.Starred, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setUserEmail = `-- name: SetUserEmail :exec
+UPDATE users
+SET email = ?, email_verified_at = NULL, email_verification_nonce = ?
+WHERE id = ?
+`
+
+type SetUserEmailParams struct {
+	Email                  sql.NullString `json:"email"`
+	EmailVerificationNonce sql.NullString `json:"email_verification_nonce"`
+	ID                     int64          `json:"id"`
+}
+
+func (q *Queries) SetUserEmail(ctx context.Context, arg SetUserEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setUserEmail, arg.Email, arg.EmailVerificationNonce, arg.ID)
+	return err
+}
+
+const setUserTOTPSecret = `-- name: SetUserTOTPSecret :exec
+UPDATE users
+SET totp_secret = ?, totp_enabled_at = NULL, totp_last_used_step = NULL, mfa_failed_attempts = 0, mfa_locked_until = NULL
+WHERE id = ?
+`
+
+type SetUserTOTPSecretParams struct {
+	TotpSecret sql.NullString `json:"totp_secret"`
+	ID         int64          `json:"id"`
+}
+
+// Starts enrolling a new TOTP secret, or removes it with a NULL secret. Either
+// way two-factor authentication is off until a code is confirmed.
+func (q *Queries) SetUserTOTPSecret(ctx context.Context, arg SetUserTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTOTPSecret, arg.TotpSecret, arg.ID)
+	return err
+}
+
+const shiftCollectionLinks = `-- name: ShiftCollectionLinks :exec
+UPDATE collection_links
+SET position = position + ?
+WHERE collection_id = ? AND position >= ?
+`
+
+type ShiftCollectionLinksParams struct {
+	Delta        int64 `json:"delta"`
+	CollectionID int64 `json:"collection_id"`
+	FromPosition int64 `json:"from_position"`
+}
+
+// Moves the links at or after a position in a collection up or down by delta.
+func (q *Queries) ShiftCollectionLinks(ctx context.Context, arg ShiftCollectionLinksParams) error {
+	_, err := q.db.ExecContext(ctx, shiftCollectionLinks, arg.Delta, arg.CollectionID, arg.FromPosition)
+	return err
+}
+
+const shiftPinnedLinks = `-- name: ShiftPinnedLinks :exec
+UPDATE links
+SET pinned_position = pinned_position + ?
+WHERE user_id = ? AND pinned_position >= ?
+`
+
+type ShiftPinnedLinksParams struct {
+	Delta        int64 `json:"delta"`
+	UserID       int64 `json:"user_id"`
+	FromPosition int64 `json:"from_position"`
+}
+
+// Moves the pinned links at or after a position up or down by delta.
+func (q *Queries) ShiftPinnedLinks(ctx context.Context, arg ShiftPinnedLinksParams) error {
+	_, err := q.db.ExecContext(ctx, shiftPinnedLinks, arg.Delta, arg.UserID, arg.FromPosition)
+	return err
+}
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE sessions
+SET last_seen_at = ?, user_agent = ?, ip_address = ?
+WHERE id = ?
+`
+
+type TouchSessionParams struct {
+	LastSeenAt sql.NullTime   `json:"last_seen_at"`
+	UserAgent  sql.NullString `json:"user_agent"`
+	IpAddress  sql.NullString `json:"ip_address"`
+	ID         int64          `json:"id"`
+}
+
+func (q *Queries) TouchSession(ctx context.Context, arg TouchSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchSession,
+		arg.LastSeenAt,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.ID,
+	)
+	return err
+}
+
+const updateCollection = `-- name: UpdateCollection :one
+UPDATE collections
+SET name = ?, parent_id = ?
+WHERE id = ? AND user_id = ?
+RETURNING id, parent_id, name, created_at
+`
+
+type UpdateCollectionParams struct {
+	Name     string        `json:"name"`
+	ParentID sql.NullInt64 `json:"parent_id"`
+	ID       int64         `json:"id"`
+	UserID   int64         `json:"user_id"`
+}
+
+type UpdateCollectionRow struct {
+	ID        int64         `json:"id"`
+	ParentID  sql.NullInt64 `json:"parent_id"`
+	Name      string        `json:"name"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+func (q *Queries) UpdateCollection(ctx context.Context, arg UpdateCollectionParams) (UpdateCollectionRow, error) {
+	row := q.db.QueryRowContext(ctx, updateCollection,
+		arg.Name,
+		arg.ParentID,
+		arg.ID,
+		arg.UserID,
+	)
+	var i UpdateCollectionRow
+	err := row.Scan(
+		&i.ID,
+		&i.ParentID,
+		&i.Name,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateHighlight = `-- name: UpdateHighlight :one
+UPDATE highlights
+SET
+    quote = COALESCE(?, quote),
+    prefix = COALESCE(?, prefix),
+    suffix = COALESCE(?, suffix),
+    comment = COALESCE(?, comment),
+    color = COALESCE(?, color),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND link_id = ? AND user_id = ?
+RETURNING id, link_id, quote, prefix, suffix, comment, color, created_at, updated_at
+`
+
+type UpdateHighlightParams struct {
+	Quote   sql.NullString `json:"quote"`
+	Prefix  sql.NullString `json:"prefix"`
+	Suffix  sql.NullString `json:"suffix"`
+	Comment sql.NullString `json:"comment"`
+	Color   sql.NullString `json:"color"`
+	ID      int64          `json:"id"`
+	LinkID  int64          `json:"link_id"`
+	UserID  int64          `json:"user_id"`
+}
+
+type UpdateHighlightRow struct {
+	ID        int64          `json:"id"`
+	LinkID    int64          `json:"link_id"`
+	Quote     string         `json:"quote"`
+	Prefix    sql.NullString `json:"prefix"`
+	Suffix    sql.NullString `json:"suffix"`
+	Comment   sql.NullString `json:"comment"`
+	Color     string         `json:"color"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (q *Queries) UpdateHighlight(ctx context.Context, arg UpdateHighlightParams) (UpdateHighlightRow, error) {
+	row := q.db.QueryRowContext(ctx, updateHighlight,
+		arg.Quote,
+		arg.Prefix,
+		arg.Suffix,
+		arg.Comment,
+		arg.Color,
+		arg.ID,
+		arg.LinkID,
+		arg.UserID,
+	)
+	var i UpdateHighlightRow
+	err := row.Scan(
+		&i.ID,
+		&i.LinkID,
+		&i.Quote,
+		&i.Prefix,
+		&i.Suffix,
+		&i.Comment,
+		&i.Color,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateLink = `-- name: UpdateLink :one
+UPDATE links
+SET
+    url = COALESCE(?, url),
+    title = COALESCE(?, title),
+    note = COALESCE(?, note),
+    domain = COALESCE(?, domain),
+    canonical_url = COALESCE(?, canonical_url)
+WHERE id = ? AND user_id = ? AND deleted_at IS NULL
+RETURNING id, url, title, note, bookmarked_at, tags, read_at, archived_at, starred, pinned_position
+`
+
+type UpdateLinkParams struct {
+	Url          sql.NullString `json:"url"`
+	Title        sql.NullString `json:"title"`
+	Note         sql.NullString `json:"note"`
+	Domain       sql.NullString `json:"domain"`
+	CanonicalUrl sql.NullString `json:"canonical_url"`
+	ID           int64          `json:"id"`
+	UserID       int64          `json:"user_id"`
+}
+
+type UpdateLinkRow struct {
+	ID             int64          `json:"id"`
+	Url            string         `json:"url"`
+	Title          string         `json:"title"`
+	Note           sql.NullString `json:"note"`
+	BookmarkedAt   time.Time      `json:"bookmarked_at"`
+	Tags           sql.NullString `json:"tags"`
+	ReadAt         sql.NullTime   `json:"read_at"`
+	ArchivedAt     sql.NullTime   `json:"archived_at"`
+	Starred        bool           `json:"starred"`
+	PinnedPosition sql.NullInt64  `json:"pinned_position"`
+}
+
+func (q *Queries) UpdateLink(ctx context.Context, arg UpdateLinkParams) (UpdateLinkRow, error) {
+	row := q.db.QueryRowContext(ctx, updateLink,
+		arg.Url,
+		arg.Title,
+		arg.Note,
+		arg.Domain,
+		arg.CanonicalUrl,
+		arg.ID,
+		arg.UserID,
+	)
+	var i UpdateLinkRow
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Title,
+		&i.Note,
+		&i.BookmarkedAt,
+		&i.Tags,
+		&i.ReadAt,
+		&i.ArchivedAt,
+		&i.Starred,
+		&i.PinnedPosition,
+	)
+	return i, err
+}
+
+const updateTemplate = `-- name: UpdateTemplate :one
+UPDATE templates
+SET
+    name = COALESCE(?, name),
+    body = COALESCE(?, body),
+    per_link = COALESCE(?, per_link),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND user_id = ?
+RETURNING id, name, body, per_link, created_at, updated_at
+`
+
+type UpdateTemplateParams struct {
+	Name    sql.NullString `json:"name"`
+	Body    sql.NullString `json:"body"`
+	PerLink sql.NullBool   `json:"per_link"`
+	ID      int64          `json:"id"`
+	UserID  int64          `json:"user_id"`
+}
+
+type UpdateTemplateRow struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	PerLink   bool      `json:"per_link"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (q *Queries) UpdateTemplate(ctx context.Context, arg UpdateTemplateParams) (UpdateTemplateRow, error) {
+	row := q.db.QueryRowContext(ctx, updateTemplate, arg.Name, arg.Body, arg.PerLink, arg.ID, arg.UserID)
+	var i UpdateTemplateRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Body,
+		&i.PerLink,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password = ?
+WHERE id = ?
+`
+
+type UpdateUserPasswordParams struct {
+	Password string `json:"password"`
+	ID       int64  `json:"id"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.Password, arg.ID)
+	return err
+}
+
+const upsertTag = `-- name: UpsertTag :one
+INSERT INTO tags (user_id, name)
+VALUES (?, ?)
+ON CONFLICT (user_id, name) DO UPDATE SET user_id = excluded.user_id
+RETURNING id
+`
+
+type UpsertTagParams struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// The no-op update makes RETURNING yield the id of an existing tag.
+func (q *Queries) UpsertTag(ctx context.Context, arg UpsertTagParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, upsertTag, arg.UserID, arg.Name)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const usePasswordResetToken = `-- name: UsePasswordResetToken :execrows
+UPDATE password_reset_tokens
+SET used_at = ?
+WHERE id = ? AND used_at IS NULL
+`
+
+type UsePasswordResetTokenParams struct {
+	UsedAt sql.NullTime `json:"used_at"`
+	ID     int64        `json:"id"`
+}
+
+func (q *Queries) UsePasswordResetToken(ctx context.Context, arg UsePasswordResetTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, usePasswordResetToken, arg.UsedAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = ?
+WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	UsedAt   sql.NullTime `json:"used_at"`
+	UserID   int64        `json:"user_id"`
+	CodeHash string       `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useRecoveryCode, arg.UsedAt, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_used_step = CAST(? AS INTEGER)
+WHERE id = ? AND (totp_last_used_step IS NULL OR totp_last_used_step < CAST(? AS INTEGER))
+`
+
+type UseTOTPStepParams struct {
+	Step int64 `json:"step"`
+	ID   int64 `json:"id"`
+}
+
+// Records the period of a TOTP code that was just used. Zero rows are
+// affected if a code of that period, or a later one, was used already.
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.Step, arg.ID, arg.Step)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = ?, email_verification_nonce = NULL
+WHERE id = ? AND email = ? AND email_verification_nonce = ?
+`
+
+type VerifyUserEmailParams struct {
+	EmailVerifiedAt        sql.NullTime   `json:"email_verified_at"`
+	ID                     int64          `json:"id"`
+	Email                  sql.NullString `json:"email"`
+	EmailVerificationNonce sql.NullString `json:"email_verification_nonce"`
+}
+
+// Marks an email address as verified. The nonce is cleared, so each
+// verification link works once and sending a new one invalidates the old.
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail,
+		arg.EmailVerifiedAt,
+		arg.ID,
+		arg.Email,
+		arg.EmailVerificationNonce,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
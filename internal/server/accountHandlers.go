@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"linkstowr/internal/auth"
+	"linkstowr/internal/notify"
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// passwordResetTokenTTL is how long a password reset token can be used.
+const passwordResetTokenTTL = time.Hour
+
+// Account is the signed-in user.
+type Account struct {
+	ID            int64  `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+	TwoFactor     bool   `json:"two_factor_enabled"`
+}
+
+func (s *Server) getAccountHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	user, err := s.repository.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newAccount(user))
+}
+
+// changePasswordHandler changes the password of a signed-in user. Every other
+// session is signed out, since whoever knew the old password could have
+// started one. API tokens are sync consumers and keep working, unless
+// revoke_api_tokens is set.
+func (s *Server) changePasswordHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var changePasswordPayload struct {
+		CurrentPassword    string `json:"current_password" validate:"required"`
+		NewPassword        string `json:"new_password" validate:"required"`
+		NewPasswordConfirm string `json:"new_password_confirm" validate:"required"`
+		RevokeAPITokens    bool   `json:"revoke_api_tokens"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&changePasswordPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(changePasswordPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if changePasswordPayload.NewPassword != changePasswordPayload.NewPasswordConfirm {
+		return echo.NewHTTPError(http.StatusBadRequest, "Passwords do not match")
+	}
+
+	ctx := c.Request().Context()
+
+	if _, err := s.checkCurrentPassword(ctx, userID, changePasswordPayload.CurrentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(changePasswordPayload.NewPassword, auth.DefaultParams)
+	if err != nil {
+		return err
+	}
+
+	// Requests keep the session or API token they were made with
+	sessionID, _ := getSessionIDFromContext(c)
+	tokenID, _ := getTokenIDFromContext(c)
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		// Reset tokens sent before the change shouldn't undo it
+		_, err := q.InvalidatePasswordResetTokens(ctx, repository.InvalidatePasswordResetTokensParams{
+			UsedAt: sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = setPassword(ctx, q, userID, hashedPassword, sessionID)
+		if err != nil || !changePasswordPayload.RevokeAPITokens {
+			return err
+		}
+
+		return q.DeleteOtherTokens(ctx, repository.DeleteOtherTokensParams{
+			UserID: userID,
+			ID:     tokenID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// createPasswordResetHandler lets an admin start a password reset for a user
+// who forgot their password. The reset token is only ever sent through the
+// notifier.
+func (s *Server) createPasswordResetHandler(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := s.repository.GetUser(ctx, c.Param("username"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	token, tokenHash, err := auth.NewSecretToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(passwordResetTokenTTL).Truncate(time.Second)
+
+	err = s.repository.CreatePasswordResetToken(ctx, repository.CreatePasswordResetTokenParams{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	var email string
+	if user.EmailVerifiedAt.Valid {
+		email = user.Email.String
+	}
+
+	// The token is sent after it's committed, so that the database isn't
+	// locked while waiting on the notifier, and deleted again if it couldn't
+	// be sent
+	err = s.notifier.Notify(ctx, notify.Message{
+		Username: user.Username,
+		Email:    email,
+		Subject:  "Reset your password",
+		Body: fmt.Sprintf("A password reset was requested for %s.\n\n"+
+			"Send this token to POST /auth/password/reset along with a new password before %s:\n\n%s\n\n"+
+			"It can only be used once.",
+			user.Username, expiresAt.Format(time.RFC1123), token),
+	})
+	if err != nil {
+		if err := s.repository.DeletePasswordResetToken(context.WithoutCancel(ctx), tokenHash); err != nil {
+			log.Printf("Failed to delete unsent password reset token of user %d: %v", user.ID, err)
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"success":    true,
+		"expires_at": expiresAt,
+	})
+}
+
+// resetPasswordHandler sets a new password with a reset token. The user is
+// signed out everywhere, and every other reset token of theirs is used up.
+// Their API tokens are only deleted if revoke_api_tokens is set.
+func (s *Server) resetPasswordHandler(c echo.Context) error {
+	var resetPasswordPayload struct {
+		Token              string `json:"token" validate:"required"`
+		NewPassword        string `json:"new_password" validate:"required"`
+		NewPasswordConfirm string `json:"new_password_confirm" validate:"required"`
+		RevokeAPITokens    bool   `json:"revoke_api_tokens"`
+	}
+
+	err := json.NewDecoder(c.Request().Body).Decode(&resetPasswordPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(resetPasswordPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if resetPasswordPayload.NewPassword != resetPasswordPayload.NewPasswordConfirm {
+		return echo.NewHTTPError(http.StatusBadRequest, "Passwords do not match")
+	}
+
+	ctx := c.Request().Context()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// The token is checked before hashing the password, so that guessing
+	// tokens doesn't cost a hash each
+	resetToken, err := s.repository.GetPasswordResetToken(ctx, auth.HashSecretToken(resetPasswordPayload.Token))
+	if err == nil && (resetToken.UsedAt.Valid || !now.Before(resetToken.ExpiresAt)) {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired password reset token")
+		}
+
+		return err
+	}
+
+	hashedPassword, err := auth.HashPassword(resetPasswordPayload.NewPassword, auth.DefaultParams)
+	if err != nil {
+		return err
+	}
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		usedAt := sql.NullTime{Time: now, Valid: true}
+
+		used, err := q.UsePasswordResetToken(ctx, repository.UsePasswordResetTokenParams{
+			UsedAt: usedAt,
+			ID:     resetToken.ID,
+		})
+		if err != nil {
+			return err
+		}
+		// A concurrent reset or password change got there first
+		if used == 0 {
+			return sql.ErrNoRows
+		}
+
+		_, err = q.InvalidatePasswordResetTokens(ctx, repository.InvalidatePasswordResetTokensParams{
+			UsedAt: usedAt,
+			UserID: resetToken.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = setPassword(ctx, q, resetToken.UserID, hashedPassword, "")
+		if err != nil || !resetPasswordPayload.RevokeAPITokens {
+			return err
+		}
+
+		return q.DeleteOtherTokens(ctx, repository.DeleteOtherTokensParams{
+			UserID: resetToken.UserID,
+		})
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired password reset token")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// setPassword stores a new password hash and revokes every session of the
+// user but keepSessionID.
+func setPassword(ctx context.Context, q *repository.Queries, userID int64, hashedPassword string, keepSessionID string) error {
+	err := q.UpdateUserPassword(ctx, repository.UpdateUserPasswordParams{
+		Password: hashedPassword,
+		ID:       userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.RevokeOtherSessions(ctx, repository.RevokeOtherSessionsParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		UserID:    userID,
+		FamilyID:  keepSessionID,
+	})
+}
+
+// checkCurrentPassword reads a signed-in user and makes sure password is
+// theirs, for changes that a stolen access token shouldn't be enough for.
+func (s *Server) checkCurrentPassword(ctx context.Context, userID int64, password string) (repository.GetUserByIDRow, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return user, echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return user, err
+	}
+
+	if ok, err := auth.ComparePasswordAndHash(password, user.Password); err != nil {
+		return user, err
+	} else if !ok {
+		return user, echo.NewHTTPError(http.StatusForbidden, "Current password is incorrect")
+	}
+
+	return user, nil
+}
+
+func newAccount(user repository.GetUserByIDRow) Account {
+	return Account{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email.String,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		TwoFactor:     user.TotpEnabledAt.Valid,
+	}
+}
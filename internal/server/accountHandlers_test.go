@@ -0,0 +1,91 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"linkstowr/internal/auth"
+	"linkstowr/internal/repository"
+)
+
+func TestPasswordResetAPITokens(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	jwt := signupTestUser(t, h, "reset")
+
+	var apiToken struct {
+		Token string `json:"token"`
+	}
+	if code := testRequest(t, h, http.MethodPost, "/api/tokens", jwt, `{"name":"reset"}`, &apiToken); code != http.StatusCreated {
+		t.Fatalf("POST /api/tokens = %d", code)
+	}
+
+	// reset resets the password with a new reset token, revoking API tokens
+	// if revokeAPITokens is "true"
+	reset := func(revokeAPITokens string) {
+		token, tokenHash, err := auth.NewSecretToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.repository.CreatePasswordResetToken(context.Background(), repository.CreatePasswordResetTokenParams{
+			UserID:    1,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().UTC().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := `{"token":"` + token + `","new_password":"new password","new_password_confirm":"new password","revoke_api_tokens":` + revokeAPITokens + `}`
+		if code := testRequest(t, h, http.MethodPost, "/auth/password/reset", "", body, nil); code != http.StatusOK {
+			t.Fatalf("POST /auth/password/reset = %d, expected 200", code)
+		}
+		if code := testRequest(t, h, http.MethodPost, "/auth/password/reset", "", body, nil); code != http.StatusBadRequest {
+			t.Errorf("reusing a password reset token = %d, expected 400", code)
+		}
+	}
+
+	reset("false")
+	if code := testRequest(t, h, http.MethodGet, "/api/account", apiToken.Token, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/account with an API token after a reset = %d, expected 200", code)
+	}
+
+	reset("true")
+	if code := testRequest(t, h, http.MethodGet, "/api/account", apiToken.Token, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/account with an API token after a reset revoking them = %d, expected 401", code)
+	}
+}
+
+func TestChangePasswordAPITokens(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "change")
+
+	var current, other struct {
+		Token string `json:"token"`
+	}
+	testRequest(t, h, http.MethodPost, "/api/tokens", jwt, `{"name":"current"}`, &current)
+	testRequest(t, h, http.MethodPost, "/api/tokens", jwt, `{"name":"other"}`, &other)
+
+	body := `{"current_password":"password","new_password":"new password","new_password_confirm":"new password"}`
+	if code := testRequest(t, h, http.MethodPost, "/api/account/password", current.Token, body, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/account/password = %d, expected 200", code)
+	}
+	if code := testRequest(t, h, http.MethodGet, "/api/account", other.Token, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/account with another API token = %d, expected 200", code)
+	}
+
+	body = `{"current_password":"new password","new_password":"password","new_password_confirm":"password","revoke_api_tokens":true}`
+	if code := testRequest(t, h, http.MethodPost, "/api/account/password", current.Token, body, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/account/password = %d, expected 200", code)
+	}
+	if code := testRequest(t, h, http.MethodGet, "/api/account", current.Token, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/account with the token that changed the password = %d, expected 200", code)
+	}
+	if code := testRequest(t, h, http.MethodGet, "/api/account", other.Token, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/account with another API token after revoking them = %d, expected 401", code)
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 
@@ -15,9 +16,12 @@ import (
 
 func (s *Server) signupHandler(c echo.Context) error {
 	var signupPayload struct {
-		Username        string `json:"username" validate:"required"`
+		// Usernames can't contain "@", so that none can be mistaken for an
+		// email address when signing in.
+		Username        string `json:"username" validate:"required,excludes=@"`
 		Password        string `json:"password" validate:"required"`
 		PasswordConfirm string `json:"password_confirm" validate:"required"`
+		Email           string `json:"email" validate:"omitempty,email,max=254"`
 	}
 
 	err := json.NewDecoder(c.Request().Body).Decode(&signupPayload)
@@ -40,9 +44,22 @@ func (s *Server) signupHandler(c echo.Context) error {
 		return err
 	}
 
-	row, err := s.repository.CreateUser(c.Request().Context(), repository.CreateUserParams{
-		Username: signupPayload.Username,
-		Password: hashedPassword,
+	ctx := c.Request().Context()
+	email := strings.TrimSpace(signupPayload.Email)
+
+	var nonce string
+	if email != "" {
+		nonce, err = auth.NewRandomID()
+		if err != nil {
+			return err
+		}
+	}
+
+	row, err := s.repository.CreateUser(ctx, repository.CreateUserParams{
+		Username:               signupPayload.Username,
+		Password:               hashedPassword,
+		Email:                  sql.NullString{String: email, Valid: email != ""},
+		EmailVerificationNonce: sql.NullString{String: nonce, Valid: nonce != ""},
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
@@ -52,20 +69,25 @@ func (s *Server) signupHandler(c echo.Context) error {
 		return err
 	}
 
-	token, err := auth.GenerateJWT(row.ID, row.Username)
+	// The account is usable without a verified address, and the link can be
+	// sent again later
+	if email != "" {
+		if err := s.sendEmailVerification(ctx, row.ID, email, nonce); err != nil {
+			log.Printf("Failed to send email verification to user %d: %v", row.ID, err)
+		}
+	}
+
+	tokens, err := s.startSession(c, row.ID, row.Username)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, echo.Map{
-		"id":       row.ID,
-		"username": row.Username,
-		"token":    token,
-	})
+	return c.JSON(http.StatusCreated, tokens)
 }
 
 func (s *Server) signinHandler(c echo.Context) error {
 	var signinPayload struct {
+		// Username is a username or a verified email address
 		Username string `json:"username" validate:"required"`
 		Password string `json:"password" validate:"required"`
 	}
@@ -80,7 +102,22 @@ func (s *Server) signinHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
 	}
 
-	row, err := s.repository.GetUser(c.Request().Context(), signinPayload.Username)
+	ctx := c.Request().Context()
+
+	// Anything with an "@" is looked up as an email address first. Only users
+	// who signed up before usernames couldn't contain one are found by
+	// username then.
+	byEmail := strings.Contains(signinPayload.Username, "@")
+
+	var row repository.GetUserRow
+	if byEmail {
+		var emailRow repository.GetUserByEmailRow
+		emailRow, err = s.repository.GetUserByEmail(ctx, sql.NullString{String: strings.TrimSpace(signinPayload.Username), Valid: true})
+		row = repository.GetUserRow(emailRow)
+	}
+	if !byEmail || err == sql.ErrNoRows {
+		row, err = s.repository.GetUser(ctx, signinPayload.Username)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid username or password")
@@ -97,17 +134,23 @@ func (s *Server) signinHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid username or password")
 	}
 
-	// Valid credentials, generate JWT
-	token, err := auth.GenerateJWT(row.ID, row.Username)
+	// Users with two-factor authentication get their tokens from mfaHandler
+	if row.TotpEnabledAt.Valid {
+		challenge, err := s.startMFAChallenge(ctx, row.ID)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, challenge)
+	}
+
+	// Valid credentials, generate JWT and refresh token
+	tokens, err := s.startSession(c, row.ID, row.Username)
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusOK, echo.Map{
-		"id":       row.ID,
-		"username": row.Username,
-		"token":    token,
-	})
+	return c.JSON(http.StatusOK, tokens)
 }
 
 func (s *Server) meHandler(c echo.Context) error {
@@ -123,6 +166,15 @@ func (s *Server) meHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: "+err.Error())
 	}
 
+	err = auth.CheckSession(c, s.repository, claims)
+	if err != nil {
+		if err == auth.ErrSessionRevoked {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid token: "+err.Error())
+		}
+
+		return err
+	}
+
 	return c.JSON(http.StatusOK, echo.Map{
 		"id":       claims.Subject,
 		"username": claims.Username,
@@ -0,0 +1,614 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Collection struct {
+	ID int64 `json:"id"`
+	// ParentID is nil for top-level collections.
+	ParentID  *int64    `json:"parent_id"`
+	Name      string    `json:"name"`
+	LinkCount int64     `json:"link_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CollectionLink struct {
+	Link
+	Position int64 `json:"position"`
+}
+
+func (s *Server) listCollectionsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collections, err := s.repository.ListCollections(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	collectionsResponse := make([]Collection, 0, len(collections))
+
+	for _, collection := range collections {
+		collectionsResponse = append(collectionsResponse, Collection{
+			ID:        collection.ID,
+			ParentID:  nullInt64Ptr(collection.ParentID),
+			Name:      collection.Name,
+			LinkCount: collection.LinkCount,
+			CreatedAt: collection.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, collectionsResponse)
+}
+
+func (s *Server) createCollectionHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var createCollectionPayload struct {
+		Name     string `json:"name" validate:"required,max=100"`
+		ParentID *int64 `json:"parent_id"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&createCollectionPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(createCollectionPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	name := strings.TrimSpace(createCollectionPayload.Name)
+	if name == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection name is required")
+	}
+
+	ctx := c.Request().Context()
+
+	var collection repository.CreateCollectionRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		var parentID sql.NullInt64
+		if createCollectionPayload.ParentID != nil {
+			parent, err := q.GetCollection(ctx, repository.GetCollectionParams{
+				ID:     *createCollectionPayload.ParentID,
+				UserID: userID,
+			})
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return echo.NewHTTPError(http.StatusNotFound, "Parent collection not found")
+				}
+
+				return err
+			}
+
+			parentID = sql.NullInt64{Int64: parent.ID, Valid: true}
+		}
+
+		collection, err = q.CreateCollection(ctx, repository.CreateCollectionParams{
+			UserID:   userID,
+			ParentID: parentID,
+			Name:     name,
+		})
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return echo.NewHTTPError(http.StatusConflict, "Collection already exists")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, Collection{
+		ID:        collection.ID,
+		ParentID:  nullInt64Ptr(collection.ParentID),
+		Name:      collection.Name,
+		CreatedAt: collection.CreatedAt,
+	})
+}
+
+// getCollectionHandler returns a collection with its links in their manual
+// order. Links in nested collections are not included.
+func (s *Server) getCollectionHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	collection, err := s.repository.GetCollection(ctx, repository.GetCollectionParams{
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+		}
+
+		return err
+	}
+
+	links, err := s.repository.ListCollectionLinks(ctx, repository.ListCollectionLinksParams{
+		CollectionID: collectionID,
+		UserID:       userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	linksResponse := make([]CollectionLink, 0, len(links))
+
+	for _, link := range links {
+		linksResponse = append(linksResponse, CollectionLink{
+			Link: Link{
+				ID:           link.ID,
+				URL:          link.Url,
+				Title:        link.Title,
+				Note:         link.Note.String,
+				BookmarkedAt: link.BookmarkedAt,
+				Tags:         splitTags(link.Tags.String),
+			},
+			Position: link.Position,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"collection": Collection{
+			ID:        collection.ID,
+			ParentID:  nullInt64Ptr(collection.ParentID),
+			Name:      collection.Name,
+			LinkCount: int64(len(links)),
+			CreatedAt: collection.CreatedAt,
+		},
+		"links": linksResponse,
+	})
+}
+
+// updateCollectionHandler renames a collection and/or moves it under another
+// parent. A parent_id of 0 moves it to the top level.
+func (s *Server) updateCollectionHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var updateCollectionPayload struct {
+		Name     *string `json:"name" validate:"omitempty,min=1,max=100"`
+		ParentID *int64  `json:"parent_id" validate:"omitempty,min=0"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updateCollectionPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(updateCollectionPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var collection repository.UpdateCollectionRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		existing, err := q.GetCollection(ctx, repository.GetCollectionParams{
+			ID:     collectionID,
+			UserID: userID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+			}
+
+			return err
+		}
+
+		name := existing.Name
+		if updateCollectionPayload.Name != nil {
+			name = strings.TrimSpace(*updateCollectionPayload.Name)
+			if name == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "Collection name is required")
+			}
+		}
+
+		parentID := existing.ParentID
+		if updateCollectionPayload.ParentID != nil {
+			parentID = sql.NullInt64{}
+			if *updateCollectionPayload.ParentID != 0 {
+				parentID = sql.NullInt64{Int64: *updateCollectionPayload.ParentID, Valid: true}
+				if err := checkCollectionParent(ctx, q, userID, collectionID, parentID.Int64); err != nil {
+					return err
+				}
+			}
+		}
+
+		collection, err = q.UpdateCollection(ctx, repository.UpdateCollectionParams{
+			Name:     name,
+			ParentID: parentID,
+			ID:       collectionID,
+			UserID:   userID,
+		})
+		return err
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return echo.NewHTTPError(http.StatusConflict, "Collection already exists")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, Collection{
+		ID:        collection.ID,
+		ParentID:  nullInt64Ptr(collection.ParentID),
+		Name:      collection.Name,
+		CreatedAt: collection.CreatedAt,
+	})
+}
+
+// deleteCollectionHandler deletes a collection and the collections nested in
+// it. Their links are kept, but no longer belong to a collection.
+func (s *Server) deleteCollectionHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.repository.DeleteCollection(c.Request().Context(), repository.DeleteCollectionParams{
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// addCollectionLinksHandler adds links to a collection, at the given position
+// or at the end. Links already in another collection are moved out of it.
+func (s *Server) addCollectionLinksHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var addLinksPayload struct {
+		LinkIDs  []int64 `json:"link_ids" validate:"required,min=1,max=500"`
+		Position *int64  `json:"position" validate:"omitempty,min=0"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&addLinksPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(addLinksPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		if err := checkCollection(ctx, q, userID, collectionID); err != nil {
+			return err
+		}
+
+		position := addLinksPayload.Position
+		for _, linkID := range addLinksPayload.LinkIDs {
+			_, err := q.GetLink(ctx, repository.GetLinkParams{
+				ID:     linkID,
+				UserID: userID,
+			})
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+				}
+
+				return err
+			}
+
+			placed, err := placeLink(ctx, q, collectionID, linkID, position)
+			if err != nil {
+				return err
+			}
+
+			// Keep the added links in the order they were given
+			if position != nil {
+				next := placed + 1
+				position = &next
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+func (s *Server) removeCollectionLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	linkID, err := getCollectionLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		if err := checkCollection(ctx, q, userID, collectionID); err != nil {
+			return err
+		}
+
+		current, err := q.GetCollectionLink(ctx, linkID)
+		if err != nil || current.CollectionID != collectionID {
+			if err == nil || err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound, "Link not found in collection")
+			}
+
+			return err
+		}
+
+		return removeCollectionLink(ctx, q, linkID, current)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// moveCollectionLinkHandler moves a link to another position in its
+// collection, or into another collection when collection_id is given.
+func (s *Server) moveCollectionLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	collectionID, err := getCollectionIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	linkID, err := getCollectionLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var moveLinkPayload struct {
+		CollectionID *int64 `json:"collection_id"`
+		Position     *int64 `json:"position" validate:"omitempty,min=0"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&moveLinkPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(moveLinkPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var position int64
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		if err := checkCollection(ctx, q, userID, collectionID); err != nil {
+			return err
+		}
+
+		current, err := q.GetCollectionLink(ctx, linkID)
+		if err != nil || current.CollectionID != collectionID {
+			if err == nil || err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound, "Link not found in collection")
+			}
+
+			return err
+		}
+
+		targetID := collectionID
+		if moveLinkPayload.CollectionID != nil && *moveLinkPayload.CollectionID != collectionID {
+			targetID = *moveLinkPayload.CollectionID
+			if err := checkCollection(ctx, q, userID, targetID); err != nil {
+				return err
+			}
+		}
+
+		position, err = placeLink(ctx, q, targetID, linkID, moveLinkPayload.Position)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success":  true,
+		"position": position,
+	})
+}
+
+// placeLink puts a link into a collection at a position, or at the end if
+// position is nil or past the end, taking it out of any collection it was in.
+// It returns the position the link ended up at.
+func placeLink(ctx context.Context, q *repository.Queries, collectionID, linkID int64, position *int64) (int64, error) {
+	current, err := q.GetCollectionLink(ctx, linkID)
+	if err == nil {
+		err = removeCollectionLink(ctx, q, linkID, current)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	count, err := q.CountCollectionLinks(ctx, collectionID)
+	if err != nil {
+		return 0, err
+	}
+
+	placed := count
+	if position != nil && *position < count {
+		placed = *position
+	}
+
+	err = q.ShiftCollectionLinks(ctx, repository.ShiftCollectionLinksParams{
+		Delta:        1,
+		CollectionID: collectionID,
+		FromPosition: placed,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return placed, q.AddCollectionLink(ctx, repository.AddCollectionLinkParams{
+		LinkID:       linkID,
+		CollectionID: collectionID,
+		Position:     placed,
+	})
+}
+
+// removeCollectionLink takes a link out of its collection and closes the gap
+// it leaves.
+func removeCollectionLink(ctx context.Context, q *repository.Queries, linkID int64, current repository.GetCollectionLinkRow) error {
+	if err := q.RemoveCollectionLink(ctx, linkID); err != nil {
+		return err
+	}
+
+	return q.ShiftCollectionLinks(ctx, repository.ShiftCollectionLinksParams{
+		Delta:        -1,
+		CollectionID: current.CollectionID,
+		FromPosition: current.Position + 1,
+	})
+}
+
+func checkCollection(ctx context.Context, q *repository.Queries, userID, collectionID int64) error {
+	_, err := q.GetCollection(ctx, repository.GetCollectionParams{
+		ID:     collectionID,
+		UserID: userID,
+	})
+	if err == sql.ErrNoRows {
+		return echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+	}
+
+	return err
+}
+
+// checkCollectionParent makes sure parentID is a collection that a collection
+// can be moved under, which rules out the collection itself and everything
+// nested in it.
+func checkCollectionParent(ctx context.Context, q *repository.Queries, userID, collectionID, parentID int64) error {
+	for id := parentID; ; {
+		if id == collectionID {
+			return echo.NewHTTPError(http.StatusBadRequest, "Collection can't be moved into itself or a collection nested in it")
+		}
+
+		parent, err := q.GetCollection(ctx, repository.GetCollectionParams{
+			ID:     id,
+			UserID: userID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound, "Parent collection not found")
+			}
+
+			return err
+		}
+
+		if !parent.ParentID.Valid {
+			return nil
+		}
+		id = parent.ParentID.Int64
+	}
+}
+
+func getCollectionIDFromParam(c echo.Context) (int64, error) {
+	collectionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Collection ID")
+	}
+
+	return collectionID, nil
+}
+
+func getCollectionLinkIDFromParam(c echo.Context) (int64, error) {
+	linkID, err := strconv.ParseInt(c.Param("link_id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Link ID")
+	}
+
+	return linkID, nil
+}
+
+func nullInt64Ptr(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Int64
+}
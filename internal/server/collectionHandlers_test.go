@@ -0,0 +1,167 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// createTestCollection creates a collection, nested in parent if it isn't 0,
+// and returns its ID.
+func createTestCollection(t *testing.T, h http.Handler, jwt, name string, parent int64) int64 {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"name":%q}`, name)
+	if parent != 0 {
+		body = fmt.Sprintf(`{"name":%q,"parent_id":%d}`, name, parent)
+	}
+
+	var collection Collection
+	if code := testRequest(t, h, http.MethodPost, "/api/collections", jwt, body, &collection); code != http.StatusCreated {
+		t.Fatalf("POST /api/collections = %d", code)
+	}
+
+	return collection.ID
+}
+
+// collectionLinkIDs returns the IDs of the links in a collection in order,
+// failing the test if their positions aren't numbered from 0 without gaps.
+func collectionLinkIDs(t *testing.T, h http.Handler, jwt string, id int64) []int64 {
+	t.Helper()
+
+	var response struct {
+		Links []CollectionLink `json:"links"`
+	}
+	path := fmt.Sprintf("/api/collections/%d", id)
+	if code := testRequest(t, h, http.MethodGet, path, jwt, "", &response); code != http.StatusOK {
+		t.Fatalf("GET %s = %d", path, code)
+	}
+
+	ids := make([]int64, 0, len(response.Links))
+	for i, link := range response.Links {
+		if link.Position != int64(i) {
+			t.Errorf("link %d of collection %d is at position %d, expected %d", link.ID, id, link.Position, i)
+		}
+		ids = append(ids, link.ID)
+	}
+
+	return ids
+}
+
+func TestCollectionLinkPositions(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "collections")
+
+	var links []int64
+	for i := range 4 {
+		links = append(links, createTestLink(t, h, jwt, i))
+	}
+	first := createTestCollection(t, h, jwt, "First", 0)
+	second := createTestCollection(t, h, jwt, "Second", 0)
+
+	request := func(method, path, body string) {
+		t.Helper()
+
+		if code := testRequest(t, h, method, path, jwt, body, nil); code != http.StatusOK {
+			t.Fatalf("%s %s = %d", method, path, code)
+		}
+	}
+	expect := func(id int64, expected ...int64) {
+		t.Helper()
+
+		if ids := collectionLinkIDs(t, h, jwt, id); !slices.Equal(ids, expected) {
+			t.Errorf("collection %d has links %v, expected %v", id, ids, expected)
+		}
+	}
+
+	request(http.MethodPost, fmt.Sprintf("/api/collections/%d/links", first), fmt.Sprintf(`{"link_ids":[%d,%d,%d]}`, links[0], links[1], links[2]))
+	expect(first, links[0], links[1], links[2])
+
+	// Inserting at a position moves the links from there on down
+	request(http.MethodPost, fmt.Sprintf("/api/collections/%d/links", first), fmt.Sprintf(`{"link_ids":[%d],"position":1}`, links[3]))
+	expect(first, links[0], links[3], links[1], links[2])
+
+	// Moving within a collection
+	request(http.MethodPost, fmt.Sprintf("/api/collections/%d/links/%d/move", first, links[2]), `{"position":0}`)
+	expect(first, links[2], links[0], links[3], links[1])
+
+	// Moving to another collection closes the gap left behind
+	request(http.MethodPost, fmt.Sprintf("/api/collections/%d/links/%d/move", first, links[0]), fmt.Sprintf(`{"collection_id":%d}`, second))
+	expect(first, links[2], links[3], links[1])
+	expect(second, links[0])
+
+	// Adding a link that's in another collection moves it
+	request(http.MethodPost, fmt.Sprintf("/api/collections/%d/links", second), fmt.Sprintf(`{"link_ids":[%d],"position":0}`, links[3]))
+	expect(first, links[2], links[1])
+	expect(second, links[3], links[0])
+
+	request(http.MethodDelete, fmt.Sprintf("/api/collections/%d/links/%d", first, links[2]), "")
+	expect(first, links[1])
+}
+
+func TestCollectionCannotMoveUnderItself(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "nesting")
+
+	parent := createTestCollection(t, h, jwt, "Parent", 0)
+	child := createTestCollection(t, h, jwt, "Child", parent)
+	grandchild := createTestCollection(t, h, jwt, "Grandchild", child)
+
+	for _, target := range []int64{parent, child, grandchild} {
+		path := fmt.Sprintf("/api/collections/%d", parent)
+		body := fmt.Sprintf(`{"parent_id":%d}`, target)
+		if code := testRequest(t, h, http.MethodPatch, path, jwt, body, nil); code != http.StatusBadRequest {
+			t.Errorf("moving a collection under collection %d = %d, expected 400", target, code)
+		}
+	}
+
+	// Moving it back to the top level is fine
+	path := fmt.Sprintf("/api/collections/%d", grandchild)
+	if code := testRequest(t, h, http.MethodPatch, path, jwt, `{"parent_id":0}`, nil); code != http.StatusOK {
+		t.Errorf("PATCH %s = %d, expected 200", path, code)
+	}
+}
+
+func TestDeleteCollectionKeepsLinks(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	jwt := signupTestUser(t, h, "delete")
+
+	link := createTestLink(t, h, jwt, 0)
+	parent := createTestCollection(t, h, jwt, "Parent", 0)
+	child := createTestCollection(t, h, jwt, "Child", parent)
+	grandchild := createTestCollection(t, h, jwt, "Grandchild", child)
+
+	path := fmt.Sprintf("/api/collections/%d/links", grandchild)
+	if code := testRequest(t, h, http.MethodPost, path, jwt, fmt.Sprintf(`{"link_ids":[%d]}`, link), nil); code != http.StatusOK {
+		t.Fatalf("POST %s = %d", path, code)
+	}
+
+	path = fmt.Sprintf("/api/collections/%d", parent)
+	if code := testRequest(t, h, http.MethodDelete, path, jwt, "", nil); code != http.StatusOK {
+		t.Fatalf("DELETE %s = %d", path, code)
+	}
+
+	for _, id := range []int64{child, grandchild} {
+		path := fmt.Sprintf("/api/collections/%d", id)
+		if code := testRequest(t, h, http.MethodGet, path, jwt, "", nil); code != http.StatusNotFound {
+			t.Errorf("GET %s after deleting its parent = %d, expected 404", path, code)
+		}
+	}
+
+	path = fmt.Sprintf("/api/links/%d", link)
+	if code := testRequest(t, h, http.MethodGet, path, jwt, "", nil); code != http.StatusOK {
+		t.Errorf("GET %s after deleting its collection = %d, expected 200", path, code)
+	}
+
+	var filed int
+	if err := s.db.GetDB().QueryRow("SELECT COUNT(*) FROM collection_links WHERE link_id = ?", link).Scan(&filed); err != nil {
+		t.Fatal(err)
+	}
+	if filed != 0 {
+		t.Errorf("link %d is still filed in %d collections", link, filed)
+	}
+}
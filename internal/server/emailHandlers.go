@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/auth"
+	"linkstowr/internal/mail"
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// emailVerificationTTL is how long an email verification link works.
+const emailVerificationTTL = 24 * time.Hour
+
+// setEmailHandler sets or, given an empty email, removes the user's email
+// address. A new address has to be verified before it can be used to sign
+// in, so a verification link is sent to it. Until then other users can set
+// the same address, so that nobody can claim an address they don't own.
+func (s *Server) setEmailHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var setEmailPayload struct {
+		Email string `json:"email" validate:"omitempty,email,max=254"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&setEmailPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(setEmailPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+	email := strings.TrimSpace(setEmailPayload.Email)
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	// Setting the address the user already has verified changes nothing
+	if user.EmailVerifiedAt.Valid && user.Email.String == email {
+		return c.JSON(http.StatusOK, newAccount(user))
+	}
+
+	var nonce string
+	if email != "" {
+		nonce, err = auth.NewRandomID()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.repository.SetUserEmail(ctx, repository.SetUserEmailParams{
+		Email:                  sql.NullString{String: email, Valid: email != ""},
+		EmailVerificationNonce: sql.NullString{String: nonce, Valid: nonce != ""},
+		ID:                     userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if email != "" {
+		if err := s.sendEmailVerification(ctx, userID, email, nonce); err != nil {
+			return err
+		}
+	}
+
+	user.Email = sql.NullString{String: email, Valid: email != ""}
+	user.EmailVerifiedAt = sql.NullTime{}
+
+	return c.JSON(http.StatusOK, newAccount(user))
+}
+
+// resendEmailVerificationHandler sends a new verification link for the
+// user's email address. Links sent before stop working.
+func (s *Server) resendEmailVerificationHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	if !user.Email.Valid {
+		return echo.NewHTTPError(http.StatusBadRequest, "No email address to verify")
+	}
+	if user.EmailVerifiedAt.Valid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email address is already verified")
+	}
+
+	nonce, err := auth.NewRandomID()
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.SetEmailVerificationNonce(ctx, repository.SetEmailVerificationNonceParams{
+		EmailVerificationNonce: sql.NullString{String: nonce, Valid: true},
+		ID:                     userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.sendEmailVerification(ctx, userID, user.Email.String, nonce); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"success": true,
+	})
+}
+
+// verifyEmailHandler is where verification links point to. It takes a GET
+// request since the links are opened from email.
+func (s *Server) verifyEmailHandler(c echo.Context) error {
+	claims, err := auth.DecodeEmailVerificationToken(c.QueryParam("token"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	verified, err := s.repository.VerifyUserEmail(c.Request().Context(), repository.VerifyUserEmailParams{
+		EmailVerifiedAt:        sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		ID:                     userID,
+		Email:                  sql.NullString{String: claims.Email, Valid: true},
+		EmailVerificationNonce: sql.NullString{String: claims.ID, Valid: true},
+	})
+	if err != nil {
+		// Another user verified the address first
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return echo.NewHTTPError(http.StatusConflict, "Email address is already verified by another account")
+		}
+
+		return err
+	}
+
+	// The link was used already, a newer one was sent or the address changed
+	if verified == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired verification link")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+		"email":   claims.Email,
+	})
+}
+
+// sendEmailVerification emails a verification link for email. The link works
+// until the nonce stored with the address changes.
+func (s *Server) sendEmailVerification(ctx context.Context, userID int64, email string, nonce string) error {
+	expiresAt := time.Now().Add(emailVerificationTTL)
+
+	token, err := auth.GenerateEmailVerificationToken(userID, email, nonce, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	link := s.publicURL + "/auth/email/verify?token=" + url.QueryEscape(token)
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Open this link to verify your email address:\n\n%s\n\n"+
+			"It works once, until %s.",
+			link, expiresAt.UTC().Format(time.RFC1123)),
+	})
+}
@@ -0,0 +1,86 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"linkstowr/internal/auth"
+)
+
+func TestUnverifiedEmailDoesNotLockOutOwner(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+
+	squatter := signupTestUser(t, h, "squatter")
+	owner := signupTestUser(t, h, "owner")
+
+	for _, jwt := range []string{squatter, owner} {
+		if code := testRequest(t, h, http.MethodPut, "/api/account/email", jwt, `{"email":"owner@example.com"}`, nil); code != http.StatusOK {
+			t.Fatalf("PUT /api/account/email = %d, expected 200", code)
+		}
+	}
+
+	// verify follows the verification link emailed to a user
+	verify := func(userID int64) int {
+		var nonce string
+		err := s.db.GetDB().QueryRow("SELECT email_verification_nonce FROM users WHERE id = ?", userID).Scan(&nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := auth.GenerateEmailVerificationToken(userID, "owner@example.com", nonce, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return testRequest(t, h, http.MethodGet, "/auth/email/verify?token="+url.QueryEscape(token), "", "", nil)
+	}
+
+	if code := verify(2); code != http.StatusOK {
+		t.Errorf("verifying the owner's address = %d, expected 200", code)
+	}
+	if code := verify(1); code != http.StatusConflict {
+		t.Errorf("verifying an address verified by another account = %d, expected 409", code)
+	}
+
+	var account Account
+	testRequest(t, h, http.MethodGet, "/api/account", owner, "", &account)
+	if !account.EmailVerified {
+		t.Error("owner's email address isn't verified")
+	}
+}
+
+func TestSigninWithEmailAheadOfUsernames(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	signupTestUser(t, h, "owner")
+
+	_, err := s.db.GetDB().Exec("UPDATE users SET email = 'owner@example.com', email_verified_at = CURRENT_TIMESTAMP WHERE username = 'owner'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"username":"owner@example.com","password":"other","password_confirm":"other"}`
+	if code := testRequest(t, h, http.MethodPost, "/signup", "", body, nil); code != http.StatusBadRequest {
+		t.Errorf("signing up with a username containing @ = %d, expected 400", code)
+	}
+
+	// A user who signed up with the address as their username before that
+	// was rejected
+	_, err = s.db.GetDB().Exec("INSERT INTO users (username, password) VALUES ('owner@example.com', 'unused')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var signedIn struct {
+		Username string `json:"username"`
+	}
+	body = `{"username":"owner@example.com","password":"password"}`
+	if code := testRequest(t, h, http.MethodPost, "/signin", "", body, &signedIn); code != http.StatusOK || signedIn.Username != "owner" {
+		t.Errorf("signing in with a verified email address = %d as %q, expected 200 as owner", code, signedIn.Username)
+	}
+}
@@ -0,0 +1,542 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/linktemplate"
+	"linkstowr/internal/netscape"
+	"linkstowr/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// exportPageSize is how many links are read from the database at a time
+// while streaming an export.
+const exportPageSize = 500
+
+// ExportLink is a link as written by the archive export.
+type ExportLink struct {
+	ID           int64       `json:"id"`
+	URL          string      `json:"url"`
+	CanonicalURL string      `json:"canonical_url"`
+	Title        string      `json:"title"`
+	Note         string      `json:"note"`
+	Tags         []string    `json:"tags"`
+	BookmarkedAt time.Time   `json:"bookmarked_at"`
+	Highlights   []Highlight `json:"highlights,omitempty"`
+}
+
+// exportCSVHeader names the columns of CSV exports. Highlights are written as
+// a JSON array, as CSV has no way to nest them.
+var exportCSVHeader = []string{"id", "url", "canonical_url", "title", "note", "tags", "bookmarked_at", "highlights"}
+
+// exportHandler streams the user's links, filtered like the link list, as
+// NDJSON, CSV or a JSON array. Rows are written as they are read from the
+// database, so archives of any size can be exported.
+func (s *Server) exportHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	filters, err := parseLinkFilters(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "ndjson"
+	}
+
+	var contentType string
+	switch format {
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "json":
+		contentType = echo.MIMEApplicationJSONCharsetUTF8
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid format, expected ndjson, csv or json")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="links.`+format+`"`)
+	res.WriteHeader(http.StatusOK)
+
+	var (
+		csvWriter *csv.Writer
+		encoder   = json.NewEncoder(res)
+		rows      int
+	)
+
+	switch format {
+	case "csv":
+		csvWriter = csv.NewWriter(res)
+		if err := csvWriter.Write(exportCSVHeader); err != nil {
+			return err
+		}
+	case "json":
+		if _, err := res.Write([]byte("[")); err != nil {
+			return err
+		}
+	}
+
+	ctx := c.Request().Context()
+
+	// Links are written a page at a time, so that the highlights of a page
+	// can be read with one query. Each page is flushed once written.
+	writeLinks := func(links []ExportLink) error {
+		linkIDs := make([]int64, 0, len(links))
+		for _, link := range links {
+			linkIDs = append(linkIDs, link.ID)
+		}
+
+		highlightsByLink, err := linkHighlights(ctx, s.repository, userID, linkIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			link.Highlights = highlightsByLink[link.ID]
+
+			switch format {
+			case "csv":
+				var highlights []byte
+				if len(link.Highlights) > 0 {
+					highlights, err = json.Marshal(link.Highlights)
+					if err != nil {
+						return err
+					}
+				}
+
+				err = csvWriter.Write([]string{
+					strconv.FormatInt(link.ID, 10),
+					link.URL,
+					link.CanonicalURL,
+					link.Title,
+					link.Note,
+					strings.Join(link.Tags, ","),
+					link.BookmarkedAt.Format(time.RFC3339),
+					string(highlights),
+				})
+			case "json":
+				if rows > 0 {
+					_, err = res.Write([]byte(","))
+				}
+				if err == nil {
+					err = encoder.Encode(link)
+				}
+			default:
+				err = encoder.Encode(link)
+			}
+			if err != nil {
+				return err
+			}
+
+			rows++
+		}
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+		}
+		res.Flush()
+
+		return nil
+	}
+
+	params := repository.ExportLinksParams{
+		UserID:  userID,
+		Tag:     filters.Tag,
+		Domain:  filters.Domain,
+		Before:  filters.Before,
+		After:   filters.After,
+		HasNote: filters.HasNote,
+		GroupID: filters.GroupID,
+		State:   filters.State,
+		Starred: filters.Starred,
+		Limit:   exportPageSize,
+	}
+	for {
+		page, err := s.repository.ExportLinks(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		links := make([]ExportLink, 0, len(page))
+		for _, row := range page {
+			links = append(links, ExportLink{
+				ID:           row.ID,
+				URL:          row.Url,
+				CanonicalURL: row.CanonicalUrl.String,
+				Title:        row.Title,
+				Note:         row.Note.String,
+				Tags:         splitTags(row.Tags.String),
+				BookmarkedAt: row.BookmarkedAt.UTC(),
+			})
+		}
+
+		if err := writeLinks(links); err != nil {
+			return err
+		}
+
+		if len(page) < exportPageSize {
+			break
+		}
+		params.CursorID = page[len(page)-1].ID
+	}
+
+	switch format {
+	case "csv":
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case "json":
+		_, err = res.Write([]byte("]\n"))
+		return err
+	}
+
+	return nil
+}
+
+// exportNetscapeHandler streams the user's links as a bookmarks.html file.
+// Tags are always written to the TAGS attribute; with tags=folders each link
+// is additionally placed in the folder of its first tag.
+func (s *Server) exportNetscapeHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	folders := false
+	switch c.QueryParam("tags") {
+	case "", "attribute":
+	case "folders":
+		folders = true
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid tags value")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="bookmarks.html"`)
+	res.WriteHeader(http.StatusOK)
+
+	w := netscape.NewWriter(res)
+
+	if folders {
+		err = s.exportNetscapeFolders(c, userID, w)
+	} else {
+		err = s.exportNetscapeFlat(c, userID, w)
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// exportNetscapeFlat writes all links into the top-level folder, newest first.
+func (s *Server) exportNetscapeFlat(c echo.Context, userID int64, w *netscape.Writer) error {
+	ctx := c.Request().Context()
+
+	var cursor linkCursor
+	for {
+		links, err := s.repository.ListLinksPage(ctx, repository.ListLinksPageParams{
+			UserID:     userID,
+			CursorTime: cursor.Time,
+			CursorID:   cursor.ID,
+			Limit:      exportPageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			err = w.Write(netscape.Bookmark{
+				URL:     link.Url,
+				Title:   link.Title,
+				Note:    link.Note.String,
+				Tags:    splitTags(link.Tags.String),
+				AddDate: link.BookmarkedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(links) < exportPageSize {
+			return nil
+		}
+
+		last := links[len(links)-1]
+		cursor = linkCursor{
+			Time: sql.NullInt64{Int64: last.BookmarkedAt.Unix(), Valid: true},
+			ID:   sql.NullInt64{Int64: last.ID, Valid: true},
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		c.Response().Flush()
+	}
+}
+
+// exportNetscapeFolders writes each link into the folder of its first tag,
+// nesting folders the same way tags are nested.
+func (s *Server) exportNetscapeFolders(c echo.Context, userID int64, w *netscape.Writer) error {
+	ctx := c.Request().Context()
+
+	params := repository.ListLinksByFolderParams{
+		UserID: userID,
+		Limit:  exportPageSize,
+	}
+	for {
+		links, err := s.repository.ListLinksByFolder(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		for _, link := range links {
+			var folders []string
+			if link.FolderKey != "" {
+				folders = strings.Split(link.FolderKey, "\x01")
+			}
+
+			err = w.Write(netscape.Bookmark{
+				URL:     link.Url,
+				Title:   link.Title,
+				Note:    link.Note.String,
+				Folders: folders,
+				Tags:    splitTags(link.Tags.String),
+				AddDate: link.BookmarkedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(links) < exportPageSize {
+			return nil
+		}
+
+		last := links[len(links)-1]
+		params.CursorKey = last.FolderKey
+		params.CursorID = last.ID
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		c.Response().Flush()
+	}
+}
+
+// exportMarkdownHandler renders the user's links through a template, by
+// default the built-in bullet list. The link list filters apply. List
+// templates produce a single markdown file, per-link templates a zip archive
+// with one note per link.
+func (s *Server) exportMarkdownHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	filters, err := parseLinkFilters(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	name := c.QueryParam("template")
+	if name == "" {
+		name = linktemplate.Builtins[0].Name
+	}
+
+	def, ok := linktemplate.Builtin(name)
+	if !ok {
+		template, err := s.repository.GetTemplateByName(ctx, repository.GetTemplateByNameParams{
+			UserID: userID,
+			Name:   name,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+			}
+
+			return err
+		}
+
+		def = linktemplate.Definition{
+			Name:    template.Name,
+			Body:    template.Body,
+			PerLink: template.PerLink,
+		}
+	}
+
+	tmpl, err := linktemplate.Parse(def)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid template: "+err.Error())
+	}
+
+	links, err := s.listAllLinks(ctx, userID, filters)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, templateTimeout)
+	defer cancel()
+
+	if !def.PerLink {
+		out, err := linktemplate.Execute(ctx, tmpl, templateData{
+			Links:      links,
+			ExportedAt: time.Now().UTC(),
+		}, maxTemplateOutput)
+		if err != nil {
+			return templateError(err)
+		}
+
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="links.md"`)
+		return c.Blob(http.StatusOK, "text/markdown; charset=utf-8", out)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	names := make(map[string]bool, len(links))
+	remaining := maxTemplateOutput
+
+	for _, link := range links {
+		out, err := linktemplate.Execute(ctx, tmpl, link, remaining)
+		if err != nil {
+			return templateError(err)
+		}
+		remaining -= len(out)
+
+		f, err := archive.Create(uniqueNoteFileName(names, link.Title, link.ID))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(out); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="links.zip"`)
+	return c.Blob(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// listAllLinks pages through all of the user's links that match the filters,
+// newest first.
+func (s *Server) listAllLinks(ctx context.Context, userID int64, filters linkFilters) ([]Link, error) {
+	links := []Link{}
+
+	var cursor linkCursor
+	for {
+		page, err := s.repository.ListLinksPage(ctx, repository.ListLinksPageParams{
+			UserID:     userID,
+			CursorTime: cursor.Time,
+			CursorID:   cursor.ID,
+			Tag:        filters.Tag,
+			Domain:     filters.Domain,
+			Before:     filters.Before,
+			After:      filters.After,
+			HasNote:    filters.HasNote,
+			GroupID:    filters.GroupID,
+			State:      filters.State,
+			Starred:    filters.Starred,
+			Limit:      exportPageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		pageLinks := make([]Link, 0, len(page))
+		for _, link := range page {
+			pageLinks = append(pageLinks, newLinkResponse(repository.GetLinkRow(link)))
+		}
+
+		if err := addHighlights(ctx, s.repository, userID, pageLinks); err != nil {
+			return nil, err
+		}
+		links = append(links, pageLinks...)
+
+		if len(page) < exportPageSize {
+			return links, nil
+		}
+
+		last := page[len(page)-1]
+		cursor = linkCursor{
+			Time: sql.NullInt64{Int64: last.BookmarkedAt.Unix(), Valid: true},
+			ID:   sql.NullInt64{Int64: last.ID, Valid: true},
+		}
+	}
+}
+
+// templateError reports why a template failed during an export.
+func templateError(err error) error {
+	switch {
+	case errors.Is(err, linktemplate.ErrOutputTooLarge):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Template output is too large")
+	case errors.Is(err, linktemplate.ErrTooManySteps), errors.Is(err, context.DeadlineExceeded):
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Template took too long to render")
+	default:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Template failed: "+err.Error())
+	}
+}
+
+// uniqueNoteFileName returns the file name of a link's note that isn't in
+// names yet, and adds it. Links with the same title get their ID appended,
+// and a counter too if that's taken as well. Names differing only in case are
+// taken to be the same, as they are on some file systems.
+func uniqueNoteFileName(names map[string]bool, title string, id int64) string {
+	base := noteName(title)
+	name := base + ".md"
+
+	for n := 1; names[strings.ToLower(name)]; n++ {
+		suffix := strconv.FormatInt(id, 10)
+		if n > 1 {
+			suffix += "-" + strconv.Itoa(n)
+		}
+		name = base + " " + suffix + ".md"
+	}
+	names[strings.ToLower(name)] = true
+
+	return name
+}
+
+// noteName turns a link title into the name of a markdown note, without
+// the extension, leaving out characters that aren't allowed in file names or
+// Obsidian note names.
+func noteName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|#^[]`, r) || r < ' ' {
+			return ' '
+		}
+		return r
+	}, title)
+
+	name = strings.Join(strings.Fields(name), " ")
+	if runes := []rune(name); len(runes) > 100 {
+		name = strings.TrimSpace(string(runes[:100]))
+	}
+	if name == "" {
+		name = "Untitled"
+	}
+
+	return name
+}
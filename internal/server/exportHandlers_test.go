@@ -0,0 +1,65 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportReadsEveryPage(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	jwt := signupTestUser(t, h, "export")
+
+	count := exportPageSize + 1
+	for i := range count {
+		url := fmt.Sprintf("https://example.com/%d", i)
+		_, err := s.db.GetDB().Exec("INSERT INTO links (url, title, user_id, canonical_url) VALUES (?, 'Example', 1, ?)", url, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var links []ExportLink
+	if code := testRequest(t, h, http.MethodGet, "/api/export?format=json", jwt, "", &links); code != http.StatusOK {
+		t.Fatalf("GET /api/export = %d", code)
+	}
+
+	if len(links) != count {
+		t.Fatalf("exported %d links, expected %d", len(links), count)
+	}
+	for i, link := range links {
+		if link.ID != int64(i+1) {
+			t.Fatalf("link %d has ID %d, expected %d", i, link.ID, i+1)
+		}
+	}
+}
+
+func TestUniqueNoteFileName(t *testing.T) {
+	long := strings.Repeat("a", 120)
+
+	links := []struct {
+		title    string
+		id       int64
+		expected string
+	}{
+		{"A 5", 1, "A 5.md"},
+		{"A", 5, "A.md"},
+		{"A", 6, "A 6.md"},
+		{"A", 5, "A 5-2.md"},
+		{"a", 7, "a 7.md"},
+		{long, 8, strings.Repeat("a", 100) + ".md"},
+		{long, 9, strings.Repeat("a", 100) + " 9.md"},
+		{"", 10, "Untitled.md"},
+	}
+
+	names := make(map[string]bool)
+	for _, link := range links {
+		if name := uniqueNoteFileName(names, link.title, link.id); name != link.expected {
+			t.Errorf("uniqueNoteFileName(%q, %d) = %q, expected %q", link.title, link.id, name, link.expected)
+		}
+	}
+}
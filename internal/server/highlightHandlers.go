@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultHighlightColor is the color of highlights created without one.
+const defaultHighlightColor = "yellow"
+
+// Highlight is a passage of a link's page. Prefix and suffix are the text
+// right before and after the quote, which tell repeated quotes apart like a
+// W3C Web Annotation TextQuoteSelector.
+type Highlight struct {
+	ID        int64     `json:"id"`
+	Quote     string    `json:"quote"`
+	Prefix    string    `json:"prefix"`
+	Suffix    string    `json:"suffix"`
+	Comment   string    `json:"comment"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Server) listHighlightsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	_, err = s.repository.GetLink(ctx, repository.GetLinkParams{
+		ID:     linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	highlights, err := s.repository.ListHighlights(ctx, repository.ListHighlightsParams{
+		LinkID: linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	highlightsResponse := make([]Highlight, 0, len(highlights))
+
+	for _, highlight := range highlights {
+		highlightsResponse = append(highlightsResponse, newHighlight(repository.GetHighlightRow(highlight)))
+	}
+
+	return c.JSON(http.StatusOK, highlightsResponse)
+}
+
+func (s *Server) createHighlightHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var createHighlightPayload struct {
+		Quote   string `json:"quote" validate:"required,max=10000"`
+		Prefix  string `json:"prefix" validate:"max=500"`
+		Suffix  string `json:"suffix" validate:"max=500"`
+		Comment string `json:"comment" validate:"max=10000"`
+		Color   string `json:"color" validate:"omitempty,oneof=yellow green blue pink purple"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&createHighlightPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(createHighlightPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	color := createHighlightPayload.Color
+	if color == "" {
+		color = defaultHighlightColor
+	}
+
+	ctx := c.Request().Context()
+
+	var highlight repository.CreateHighlightRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		_, err := q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		highlight, err = q.CreateHighlight(ctx, repository.CreateHighlightParams{
+			LinkID:  linkID,
+			UserID:  userID,
+			Quote:   createHighlightPayload.Quote,
+			Prefix:  sql.NullString{String: createHighlightPayload.Prefix, Valid: createHighlightPayload.Prefix != ""},
+			Suffix:  sql.NullString{String: createHighlightPayload.Suffix, Valid: createHighlightPayload.Suffix != ""},
+			Comment: sql.NullString{String: createHighlightPayload.Comment, Valid: createHighlightPayload.Comment != ""},
+			Color:   color,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newHighlight(repository.GetHighlightRow(highlight)))
+}
+
+func (s *Server) getHighlightHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	highlightID, err := getHighlightIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	highlight, err := s.repository.GetHighlight(c.Request().Context(), repository.GetHighlightParams{
+		ID:     highlightID,
+		LinkID: linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Highlight not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newHighlight(highlight))
+}
+
+func (s *Server) updateHighlightHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	highlightID, err := getHighlightIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var updateHighlightPayload struct {
+		Quote   *string `json:"quote" validate:"omitempty,min=1,max=10000"`
+		Prefix  *string `json:"prefix" validate:"omitempty,max=500"`
+		Suffix  *string `json:"suffix" validate:"omitempty,max=500"`
+		Comment *string `json:"comment" validate:"omitempty,max=10000"`
+		Color   *string `json:"color" validate:"omitempty,oneof=yellow green blue pink purple"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updateHighlightPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(updateHighlightPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	highlight, err := s.repository.UpdateHighlight(c.Request().Context(), repository.UpdateHighlightParams{
+		Quote:   toNullString(updateHighlightPayload.Quote),
+		Prefix:  toNullString(updateHighlightPayload.Prefix),
+		Suffix:  toNullString(updateHighlightPayload.Suffix),
+		Comment: toNullString(updateHighlightPayload.Comment),
+		Color:   toNullString(updateHighlightPayload.Color),
+		ID:      highlightID,
+		LinkID:  linkID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Highlight not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newHighlight(repository.GetHighlightRow(highlight)))
+}
+
+func (s *Server) deleteHighlightHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	highlightID, err := getHighlightIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.repository.DeleteHighlight(c.Request().Context(), repository.DeleteHighlightParams{
+		ID:     highlightID,
+		LinkID: linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Highlight not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// linkHighlights reads the highlights of several links at once, keyed by link
+// ID.
+func linkHighlights(ctx context.Context, q *repository.Queries, userID int64, linkIDs []int64) (map[int64][]Highlight, error) {
+	highlightsByLink := make(map[int64][]Highlight)
+	if len(linkIDs) == 0 {
+		return highlightsByLink, nil
+	}
+
+	highlights, err := q.ListHighlightsByLinkIDs(ctx, repository.ListHighlightsByLinkIDsParams{
+		UserID:  userID,
+		LinkIds: linkIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, highlight := range highlights {
+		highlightsByLink[highlight.LinkID] = append(highlightsByLink[highlight.LinkID], newHighlight(repository.GetHighlightRow(highlight)))
+	}
+
+	return highlightsByLink, nil
+}
+
+// addHighlights fills in the highlights of links.
+func addHighlights(ctx context.Context, q *repository.Queries, userID int64, links []Link) error {
+	linkIDs := make([]int64, 0, len(links))
+	for _, link := range links {
+		linkIDs = append(linkIDs, link.ID)
+	}
+
+	highlightsByLink, err := linkHighlights(ctx, q, userID, linkIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range links {
+		links[i].Highlights = highlightsByLink[links[i].ID]
+	}
+
+	return nil
+}
+
+func newHighlight(highlight repository.GetHighlightRow) Highlight {
+	return Highlight{
+		ID:        highlight.ID,
+		Quote:     highlight.Quote,
+		Prefix:    highlight.Prefix.String,
+		Suffix:    highlight.Suffix.String,
+		Comment:   highlight.Comment.String,
+		Color:     highlight.Color,
+		CreatedAt: highlight.CreatedAt,
+		UpdatedAt: highlight.UpdatedAt,
+	}
+}
+
+func getHighlightIDFromParam(c echo.Context) (int64, error) {
+	highlightID, err := strconv.ParseInt(c.Param("highlight_id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Highlight ID")
+	}
+
+	return highlightID, nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/importer"
+	"linkstowr/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxImportSize is the largest export file accepted for import.
+const maxImportSize = 32 << 20
+
+// Import statuses of a record in a dry run preview.
+const (
+	importStatusNew       = "new"
+	importStatusDuplicate = "duplicate"
+	importStatusSkipped   = "skipped"
+)
+
+// errDryRun rolls back the transaction of a dry run import.
+var errDryRun = errors.New("dry run")
+
+// ImportPreview describes what importing a record would do.
+type ImportPreview struct {
+	URL          string     `json:"url"`
+	Title        string     `json:"title"`
+	Tags         []string   `json:"tags"`
+	BookmarkedAt *time.Time `json:"bookmarked_at"`
+	Read         bool       `json:"read"`
+	Status       string     `json:"status"`
+}
+
+// importHandler imports the export file of another service, either as the
+// raw request body or as the "file" field of a multipart form. The format is
+// one of the parsers in the importer package. Links that already exist are
+// counted as duplicates and left alone, unless on_conflict=merge is passed.
+// With dry_run=true nothing is written and the response previews each link.
+func (s *Server) importHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	parse, ok := importer.Lookup(c.Param("format"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Unknown import format")
+	}
+
+	onConflict := c.QueryParam("on_conflict")
+	if onConflict != "" && onConflict != "return" && onConflict != onConflictMerge {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid on_conflict value")
+	}
+
+	dryRun := false
+	if dryRunParam := c.QueryParam("dry_run"); dryRunParam != "" {
+		dryRun, err = strconv.ParseBool(dryRunParam)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid dry_run value")
+		}
+	}
+
+	file, err := importFile(c)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, err := parse(file)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid import file: "+err.Error())
+	}
+
+	var imported, skipped, duplicates int
+	var previews []ImportPreview
+	ctx := c.Request().Context()
+
+	// Dry runs go through the same inserts so that duplicates, including
+	// duplicates within the file, are detected the same way
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		for _, record := range records {
+			link := newLink{
+				URL:          record.URL,
+				Title:        record.Title,
+				Note:         record.Note,
+				Tags:         normalizeTags(record.Tags),
+				BookmarkedAt: record.Timestamp,
+			}
+			if link.Title == "" {
+				link.Title = link.URL
+			}
+			// Exports don't say when links were read, so the time they were
+			// saved is the closest guess
+			if record.Read {
+				link.ReadAt = record.Timestamp
+				if link.ReadAt.IsZero() {
+					link.ReadAt = time.Now()
+				}
+			}
+
+			status := importStatusSkipped
+			if isImportableURL(link.URL) {
+				_, duplicate, err := createLink(ctx, q, userID, link, onConflict)
+				if err != nil {
+					return err
+				}
+
+				status = importStatusNew
+				if duplicate {
+					status = importStatusDuplicate
+				}
+			}
+
+			switch status {
+			case importStatusNew:
+				imported++
+			case importStatusDuplicate:
+				duplicates++
+			default:
+				skipped++
+			}
+
+			if dryRun {
+				previews = append(previews, ImportPreview{
+					URL:          link.URL,
+					Title:        link.Title,
+					Tags:         link.Tags,
+					BookmarkedAt: optionalTime(record.Timestamp),
+					Read:         record.Read,
+					Status:       status,
+				})
+			}
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+
+		return nil
+	})
+	if err != nil && err != errDryRun {
+		return err
+	}
+
+	response := echo.Map{
+		"imported":   imported,
+		"skipped":    skipped,
+		"duplicates": duplicates,
+	}
+	if dryRun {
+		response["dry_run"] = true
+		response["links"] = previews
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// importFile returns the uploaded file of an import request.
+func importFile(c echo.Context) (io.ReadCloser, error) {
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxImportSize)
+
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		return req.Body, nil
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Missing import file")
+	}
+
+	return header.Open()
+}
+
+// isImportableURL rejects bookmarklets and browser-internal URLs such as
+// place: and chrome:// that can't be opened from anywhere else.
+func isImportableURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,45 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestImportKeepsReadState(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "import")
+
+	body := "URL,Title,Selection,Folder,Timestamp,Tags\n" +
+		"https://go.dev/,Go,,Unread,1700000000,[]\n" +
+		"https://sqlite.org/,SQLite,,Archive,1600000000,[]\n"
+	if code := testRequest(t, h, http.MethodPost, "/api/import/instapaper", jwt, body, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/import/instapaper = %d", code)
+	}
+
+	// Archived links were read, at the latest when they were saved
+	readAt := time.Unix(1600000000, 0).UTC()
+
+	tests := []struct {
+		id       int64
+		expected *time.Time
+	}{
+		{1, nil},
+		{2, &readAt},
+	}
+
+	for _, tt := range tests {
+		var link Link
+		path := "/api/links/" + strconv.FormatInt(tt.id, 10)
+		if code := testRequest(t, h, http.MethodGet, path, jwt, "", &link); code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, code)
+		}
+
+		if (link.ReadAt == nil) != (tt.expected == nil) || (link.ReadAt != nil && !link.ReadAt.Equal(*tt.expected)) {
+			t.Errorf("%s read at %v, expected %v", link.URL, link.ReadAt, tt.expected)
+		}
+	}
+}
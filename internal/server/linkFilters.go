@@ -0,0 +1,192 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// pageParams are the query parameters that opt a link listing into
+// pagination. Requests without any of them get the legacy unpaginated array.
+var pageParams = []string{"limit", "cursor", "tag", "domain", "before", "after", "has_note", "group_id", "state", "starred"}
+
+// linkFilters are the optional query parameters used to narrow down a user's
+// links. Zero values mean the filter is not applied.
+type linkFilters struct {
+	Tag     sql.NullString
+	Domain  sql.NullString
+	Before  sql.NullInt64
+	After   sql.NullInt64
+	HasNote sql.NullBool
+	GroupID sql.NullString
+	State   sql.NullString
+	Starred sql.NullBool
+}
+
+// linkCursor is a position in the (bookmarked_at, id) keyset ordering.
+type linkCursor struct {
+	Time sql.NullInt64
+	ID   sql.NullInt64
+}
+
+func hasPageParams(c echo.Context) bool {
+	query := c.QueryParams()
+	for _, param := range pageParams {
+		if query.Has(param) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseLinkFilters(c echo.Context) (linkFilters, error) {
+	var filters linkFilters
+
+	// Nested tags match their descendants too, so "dev" also matches "dev/go"
+	if tag := c.QueryParam("tag"); strings.TrimSpace(tag) != "" {
+		tags := normalizeTags([]string{tag})
+		if len(tags) != 1 {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid tag, only one tag can be filtered by")
+		}
+		filters.Tag = sql.NullString{String: tags[0], Valid: true}
+	}
+
+	if domain := strings.TrimSpace(c.QueryParam("domain")); domain != "" {
+		filters.Domain = sql.NullString{String: normalizeDomain(domain), Valid: true}
+	}
+
+	if before := c.QueryParam("before"); before != "" {
+		t, err := parseFilterTime(before)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid before date")
+		}
+		filters.Before = sql.NullInt64{Int64: t.Unix(), Valid: true}
+	}
+
+	if after := c.QueryParam("after"); after != "" {
+		t, err := parseFilterTime(after)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid after date")
+		}
+		filters.After = sql.NullInt64{Int64: t.Unix(), Valid: true}
+	}
+
+	if hasNote := c.QueryParam("has_note"); hasNote != "" {
+		b, err := strconv.ParseBool(hasNote)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid has_note value")
+		}
+		filters.HasNote = sql.NullBool{Bool: b, Valid: true}
+	}
+
+	if groupID := c.QueryParam("group_id"); groupID != "" {
+		filters.GroupID = sql.NullString{String: groupID, Valid: true}
+	}
+
+	if state := c.QueryParam("state"); state != "" {
+		if state != linkStateUnread && state != linkStateRead && state != linkStateArchived {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid state")
+		}
+		filters.State = sql.NullString{String: state, Valid: true}
+	}
+
+	if starred := c.QueryParam("starred"); starred != "" {
+		b, err := strconv.ParseBool(starred)
+		if err != nil {
+			return filters, echo.NewHTTPError(http.StatusBadRequest, "Invalid starred value")
+		}
+		filters.Starred = sql.NullBool{Bool: b, Valid: true}
+	}
+
+	return filters, nil
+}
+
+// parsePageLimit reads the limit query parameter, clamping it to maxPageLimit.
+func parsePageLimit(c echo.Context) (int, error) {
+	limitParam := c.QueryParam("limit")
+	if limitParam == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+	}
+
+	return min(limit, maxPageLimit), nil
+}
+
+// parseFilterTime accepts either an RFC 3339 timestamp or a plain date.
+func parseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
+
+func encodeLinkCursor(bookmarkedAt time.Time, id int64) string {
+	raw := fmt.Sprintf("%d:%d", bookmarkedAt.Unix(), id)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLinkCursor(cursor string) (linkCursor, error) {
+	if cursor == "" {
+		return linkCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return linkCursor{}, err
+	}
+
+	timePart, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return linkCursor{}, errors.New("malformed cursor")
+	}
+
+	t, err := strconv.ParseInt(timePart, 10, 64)
+	if err != nil {
+		return linkCursor{}, err
+	}
+
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return linkCursor{}, err
+	}
+
+	return linkCursor{
+		Time: sql.NullInt64{Int64: t, Valid: true},
+		ID:   sql.NullInt64{Int64: id, Valid: true},
+	}, nil
+}
+
+// linkDomain returns the normalized host of a link's URL, which is what the
+// domain filter matches against.
+func linkDomain(rawURL string) sql.NullString {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: normalizeDomain(u.Hostname()), Valid: true}
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(domain), "www.")
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestLinkCursorRoundTrip(t *testing.T) {
+	bookmarkedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	cursor, err := decodeLinkCursor(encodeLinkCursor(bookmarkedAt, 42))
+	if err != nil {
+		t.Fatalf("decodeLinkCursor() error = %v", err)
+	}
+	if cursor.Time.Int64 != bookmarkedAt.Unix() || cursor.ID.Int64 != 42 {
+		t.Errorf("decodeLinkCursor() = %+v, expected time %d and id 42", cursor, bookmarkedAt.Unix())
+	}
+
+	if _, err := decodeLinkCursor("not-a-cursor"); err == nil {
+		t.Errorf("decodeLinkCursor() expected error for malformed cursor")
+	}
+}
+
+func TestLinkDomain(t *testing.T) {
+	tests := map[string]string{
+		"https://www.Example.com/path": "example.com",
+		"http://blog.example.com:8080": "blog.example.com",
+		"not a url":                    "",
+	}
+
+	for rawURL, expected := range tests {
+		if actual := linkDomain(rawURL).String; actual != expected {
+			t.Errorf("linkDomain(%q) = %q, expected %q", rawURL, actual, expected)
+		}
+	}
+}
+
+func TestParseLinkFiltersTag(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected sql.NullString
+		invalid  bool
+	}{
+		{"", sql.NullString{}, false},
+		{"tag=+", sql.NullString{}, false},
+		{"tag=Dev/Go", sql.NullString{String: "dev/go", Valid: true}, false},
+		{"tag=a,b", sql.NullString{}, true},
+		{"tag=,", sql.NullString{}, true},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/links?"+tt.query, nil)
+		filters, err := parseLinkFilters(e.NewContext(req, httptest.NewRecorder()))
+
+		if tt.invalid {
+			var httpErr *echo.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+				t.Errorf("parseLinkFilters(%q) error = %v, expected a 400", tt.query, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("parseLinkFilters(%q) error = %v", tt.query, err)
+		} else if filters.Tag != tt.expected {
+			t.Errorf("parseLinkFilters(%q) tag = %v, expected %v", tt.query, filters.Tag, tt.expected)
+		}
+	}
+}
@@ -1,18 +1,58 @@
 package server
 
 import (
+	"cmp"
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"linkstowr/internal/canonicalurl"
 	"linkstowr/internal/repository"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	defaultClaimLimit = 100
+	maxClaimLimit     = 500
+
+	defaultSyncLimit = 100
+	maxSyncLimit     = 500
+
+	// linkLeaseDuration is how long claimed links stay reserved for a consumer
+	// before they are handed out again.
+	linkLeaseDuration = 5 * time.Minute
+)
+
 type Link struct {
+	ID           int64     `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	Note         string    `json:"note"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+	Tags         []string  `json:"tags"`
+	// The reading state, star, pin and highlights are left out unless they
+	// are set.
+	ReadAt         *time.Time  `json:"read_at,omitempty"`
+	ArchivedAt     *time.Time  `json:"archived_at,omitempty"`
+	Starred        bool        `json:"starred,omitempty"`
+	PinnedPosition *int64      `json:"pinned_position,omitempty"`
+	Highlights     []Highlight `json:"highlights,omitempty"`
+}
+
+// LegacyLink is the shape of links in the unpaginated GET /api/links
+// response, which still returns tags as a comma-separated string.
+//
+// Deprecated: kept for Obsidian plugin versions that predate tag arrays.
+type LegacyLink struct {
+	ID           int64     `json:"id"`
 	URL          string    `json:"url"`
 	Title        string    `json:"title"`
 	Note         string    `json:"note"`
@@ -26,15 +66,24 @@ func (s *Server) listLinksHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
 	}
 
+	if since := c.QueryParam("since"); since != "" {
+		return s.syncLinks(c, userID, since)
+	}
+
+	if hasPageParams(c) {
+		return s.listLinksPage(c, userID)
+	}
+
 	links, err := s.repository.ListLinks(c.Request().Context(), userID)
 	if err != nil {
 		return err
 	}
 
-	linksResponse := make([]Link, 0)
+	linksResponse := make([]LegacyLink, 0)
 
 	for _, link := range links {
-		linksResponse = append(linksResponse, Link{
+		linksResponse = append(linksResponse, LegacyLink{
+			ID:           link.ID,
 			URL:          link.Url,
 			Title:        link.Title,
 			Note:         link.Note.String,
@@ -46,6 +95,200 @@ func (s *Server) listLinksHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, linksResponse)
 }
 
+// listLinksPage returns one page of the user's links, newest first, narrowed
+// down by the filters in the query string. The first page also starts with
+// all of the pinned links that match the filters, in their pinned order, on
+// top of the limit; pinned links are left out of the pages themselves.
+func (s *Server) listLinksPage(c echo.Context, userID int64) error {
+	filters, err := parseLinkFilters(c)
+	if err != nil {
+		return err
+	}
+
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := decodeLinkCursor(c.QueryParam("cursor"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid cursor")
+	}
+
+	params := repository.ListLinksPageParams{
+		UserID:     userID,
+		CursorTime: cursor.Time,
+		CursorID:   cursor.ID,
+		Tag:        filters.Tag,
+		Domain:     filters.Domain,
+		Before:     filters.Before,
+		After:      filters.After,
+		HasNote:    filters.HasNote,
+		GroupID:    filters.GroupID,
+		State:      filters.State,
+		Starred:    filters.Starred,
+		Pinned:     sql.NullBool{Bool: false, Valid: true},
+		// Fetch one extra row to find out whether there is another page
+		Limit: int64(limit + 1),
+	}
+
+	ctx := c.Request().Context()
+
+	var pinned []repository.ListLinksPageRow
+	if !cursor.ID.Valid {
+		pinnedParams := params
+		pinnedParams.Pinned = sql.NullBool{Bool: true, Valid: true}
+		pinnedParams.Limit = maxPinnedLinks
+
+		pinned, err = s.repository.ListLinksPage(ctx, pinnedParams)
+		if err != nil {
+			return err
+		}
+
+		slices.SortFunc(pinned, func(a, b repository.ListLinksPageRow) int {
+			return cmp.Or(
+				cmp.Compare(a.PinnedPosition.Int64, b.PinnedPosition.Int64),
+				cmp.Compare(a.ID, b.ID),
+			)
+		})
+	}
+
+	links, err := s.repository.ListLinksPage(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	var nextCursor *string
+	if len(links) > limit {
+		links = links[:limit]
+		last := links[len(links)-1]
+		next := encodeLinkCursor(last.BookmarkedAt, last.ID)
+		nextCursor = &next
+	}
+
+	linksResponse := make([]Link, 0, len(pinned)+len(links))
+
+	for _, link := range slices.Concat(pinned, links) {
+		linksResponse = append(linksResponse, newLinkResponse(repository.GetLinkRow(link)))
+	}
+
+	if err := addHighlights(ctx, s.repository, userID, linksResponse); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"links":       linksResponse,
+		"next_cursor": nextCursor,
+	})
+}
+
+// syncLinks returns the links saved after the since cursor. Every API token
+// acts as a sync consumer: passing a cursor confirms that every link up to it
+// was received, up to the last link the token was sent. Links are moved to
+// the trash once all of the user's tokens have moved past them, or once they
+// are older than the retention window and at least one token has received
+// them.
+func (s *Server) syncLinks(c echo.Context, userID int64, sinceParam string) error {
+	since, err := strconv.ParseInt(sinceParam, 10, 64)
+	if err != nil || since < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid since cursor")
+	}
+
+	limit := defaultSyncLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = min(limit, maxSyncLimit)
+	}
+
+	ctx := c.Request().Context()
+
+	if tokenID, ok := getTokenIDFromContext(c); ok {
+		now := time.Now().UTC()
+
+		err = s.repository.AdvanceTokenCursor(ctx, repository.AdvanceTokenCursorParams{
+			SyncCursor: since,
+			SyncedAt:   sql.NullTime{Time: now, Valid: true},
+			ID:         tokenID,
+			UserID:     userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.withTx(ctx, func(q *repository.Queries) error {
+			pruned, err := q.PruneSyncedLinks(ctx, repository.PruneSyncedLinksParams{
+				UserID:          userID,
+				RetentionCutoff: now.Add(-s.linkRetention),
+			})
+			if err != nil || pruned == 0 {
+				return err
+			}
+
+			return q.CompactPinnedLinks(ctx, userID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Fetch one extra row to find out whether there is another page
+	links, err := s.repository.ListLinksSince(ctx, repository.ListLinksSinceParams{
+		UserID: userID,
+		Since:  since,
+		Limit:  int64(limit + 1),
+	})
+	if err != nil {
+		return err
+	}
+
+	hasMore := len(links) > limit
+	if hasMore {
+		links = links[:limit]
+	}
+
+	nextCursor := since
+	linksResponse := make([]Link, 0, len(links))
+
+	for _, link := range links {
+		linksResponse = append(linksResponse, Link{
+			ID:           link.ID,
+			URL:          link.Url,
+			Title:        link.Title,
+			Note:         link.Note.String,
+			BookmarkedAt: link.BookmarkedAt,
+			Tags:         splitTags(link.Tags.String),
+		})
+		nextCursor = link.ID
+	}
+
+	if err := addHighlights(ctx, s.repository, userID, linksResponse); err != nil {
+		return err
+	}
+
+	if tokenID, ok := getTokenIDFromContext(c); ok && len(links) > 0 {
+		err = s.repository.MarkTokenDelivered(ctx, repository.MarkTokenDeliveredParams{
+			SyncDelivered: nextCursor,
+			ID:            tokenID,
+			UserID:        userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"links":       linksResponse,
+		"next_cursor": nextCursor,
+		"has_more":    hasMore,
+	})
+}
+
+// createLinkHandler saves a link. Links are deduplicated on their canonical
+// URL: saving a link that already exists returns the existing link, or with
+// on_conflict set to "merge", adds the new note and tags to it.
 func (s *Server) createLinkHandler(c echo.Context) error {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -53,10 +296,14 @@ func (s *Server) createLinkHandler(c echo.Context) error {
 	}
 
 	var createLinkPayload struct {
-		URL   string `json:"url" validate:"required,url"`
-		Title string `json:"title" validate:"required"`
-		Note  string `json:"note"`
-		Tags  string `json:"tags"`
+		URL        string  `json:"url" validate:"required,url"`
+		Title      string  `json:"title" validate:"required"`
+		Note       string  `json:"note"`
+		Tags       TagList `json:"tags"`
+		OnConflict string  `json:"on_conflict" validate:"omitempty,oneof=return merge"`
+		// CollectionID optionally files the link, new or duplicate, at the
+		// end of a collection.
+		CollectionID *int64 `json:"collection_id"`
 	}
 
 	err = json.NewDecoder(c.Request().Body).Decode(&createLinkPayload)
@@ -70,25 +317,317 @@ func (s *Server) createLinkHandler(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
 	}
 
-	row, err := s.repository.CreateLink(c.Request().Context(), repository.CreateLinkParams{
-		UserID: userID,
-		Url:    createLinkPayload.URL,
-		Title:  createLinkPayload.Title,
-		Note:   sql.NullString{String: createLinkPayload.Note, Valid: createLinkPayload.Note != ""},
-		Tags:   sql.NullString{String: createLinkPayload.Tags, Valid: createLinkPayload.Tags != ""},
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	var duplicate bool
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		if createLinkPayload.CollectionID != nil {
+			if err := checkCollection(ctx, q, userID, *createLinkPayload.CollectionID); err != nil {
+				return err
+			}
+		}
+
+		var linkID int64
+		linkID, duplicate, err = createLink(ctx, q, userID, newLink{
+			URL:   createLinkPayload.URL,
+			Title: createLinkPayload.Title,
+			Note:  createLinkPayload.Note,
+			Tags:  createLinkPayload.Tags,
+		}, createLinkPayload.OnConflict)
+		if err != nil {
+			return err
+		}
+
+		if createLinkPayload.CollectionID != nil {
+			if _, err := placeLink(ctx, q, *createLinkPayload.CollectionID, linkID, nil); err != nil {
+				return err
+			}
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
 	})
 	if err != nil {
 		return err
 	}
 
-	return c.JSON(http.StatusCreated, echo.Map{
+	status := http.StatusCreated
+	if duplicate {
+		status = http.StatusOK
+	}
+
+	return c.JSON(status, echo.Map{
 		"result": echo.Map{
-			"url":     row.Url,
-			"success": true,
+			"id":        link.ID,
+			"url":       link.Url,
+			"success":   true,
+			"duplicate": duplicate,
+			"link":      newLinkResponse(link),
 		},
 	})
 }
 
+// createLinksBatchHandler saves several links in one transaction, such as all
+// tabs of a browser window. Each link is validated on its own and gets its own
+// entry in the results; invalid links are skipped. With group set, the newly
+// created links share a group_id that can be passed to GET /api/links to
+// recall them. Duplicates keep their existing group.
+func (s *Server) createLinksBatchHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	type batchLink struct {
+		URL   string  `json:"url" validate:"required,url"`
+		Title string  `json:"title" validate:"required"`
+		Note  string  `json:"note"`
+		Tags  TagList `json:"tags"`
+	}
+
+	var createLinksBatchPayload struct {
+		Links      []batchLink `json:"links" validate:"required,min=1"`
+		OnConflict string      `json:"on_conflict" validate:"omitempty,oneof=return merge"`
+		Group      bool        `json:"group"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&createLinksBatchPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(createLinksBatchPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if len(createLinksBatchPayload.Links) > s.linkBatchLimit {
+		return echo.NewHTTPError(http.StatusBadRequest, "Too many links, at most "+strconv.Itoa(s.linkBatchLimit)+" can be saved at once")
+	}
+
+	var groupID string
+	if createLinksBatchPayload.Group {
+		groupID = rand.Text()
+	}
+
+	results := make([]echo.Map, len(createLinksBatchPayload.Links))
+	ctx := c.Request().Context()
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		for i, link := range createLinksBatchPayload.Links {
+			if err := v.Struct(link); err != nil {
+				results[i] = echo.Map{
+					"url":     link.URL,
+					"success": false,
+					"error":   "Validation failed: " + err.Error(),
+				}
+				continue
+			}
+
+			linkID, duplicate, err := createLink(ctx, q, userID, newLink{
+				URL:     link.URL,
+				Title:   link.Title,
+				Note:    link.Note,
+				Tags:    link.Tags,
+				GroupID: groupID,
+			}, createLinksBatchPayload.OnConflict)
+			if err != nil {
+				return err
+			}
+
+			results[i] = echo.Map{
+				"id":        linkID,
+				"url":       link.URL,
+				"success":   true,
+				"duplicate": duplicate,
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	response := echo.Map{
+		"results": results,
+	}
+	if groupID != "" {
+		response["group_id"] = groupID
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (s *Server) getLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	link, err := s.repository.GetLink(ctx, repository.GetLinkParams{
+		ID:     linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	linksResponse := []Link{newLinkResponse(link)}
+	if err := addHighlights(ctx, s.repository, userID, linksResponse); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, linksResponse[0])
+}
+
+func (s *Server) updateLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	// Fields left out of the payload keep their current value
+	var updateLinkPayload struct {
+		URL   *string  `json:"url" validate:"omitnil,url"`
+		Title *string  `json:"title" validate:"omitnil,min=1"`
+		Note  *string  `json:"note"`
+		Tags  *TagList `json:"tags"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updateLinkPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(updateLinkPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	params := repository.UpdateLinkParams{
+		Url:    toNullString(updateLinkPayload.URL),
+		Title:  toNullString(updateLinkPayload.Title),
+		Note:   toNullString(updateLinkPayload.Note),
+		ID:     linkID,
+		UserID: userID,
+	}
+	if updateLinkPayload.URL != nil {
+		params.Domain = linkDomain(*updateLinkPayload.URL)
+		params.CanonicalUrl = sql.NullString{String: canonicalurl.Canonicalize(*updateLinkPayload.URL), Valid: true}
+	}
+
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		// Updating first also checks that the link belongs to the user
+		if _, err := q.UpdateLink(ctx, params); err != nil {
+			return err
+		}
+
+		if updateLinkPayload.Tags != nil {
+			if err := setLinkTags(ctx, q, userID, linkID, *updateLinkPayload.Tags); err != nil {
+				return err
+			}
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			return echo.NewHTTPError(http.StatusConflict, "A link with this URL already exists")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newLinkResponse(link))
+}
+
+// deleteLinkHandler moves a link to the trash, from where it can be restored
+// until it is purged.
+func (s *Server) deleteLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		link, err := q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Trashed links aren't pinned, so the pins after it move up
+		if link.PinnedPosition.Valid {
+			if err := unpinLink(ctx, q, userID, link); err != nil {
+				return err
+			}
+		}
+
+		_, err = q.DeleteLink(ctx, repository.DeleteLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// clearLinksHandler moves all of the user's links to the trash.
+//
+// Deprecated: links saved between listing and clearing are lost. Consumers
+// should use claimLinksHandler and ackLinksHandler instead.
 func (s *Server) clearLinksHandler(c echo.Context) error {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -104,3 +643,275 @@ func (s *Server) clearLinksHandler(c echo.Context) error {
 		"success": true,
 	})
 }
+
+// claimLinksHandler leases a batch of the user's links to the caller. The
+// links stay in place until they are acknowledged with the returned lease ID,
+// and are handed out again once the lease expires.
+func (s *Server) claimLinksHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	limit := defaultClaimLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = min(limit, maxClaimLimit)
+	}
+
+	leaseID := rand.Text()
+	now := time.Now().UTC()
+	expiresAt := now.Add(linkLeaseDuration)
+
+	links, err := s.repository.ClaimLinks(c.Request().Context(), repository.ClaimLinksParams{
+		LeaseID:        sql.NullString{String: leaseID, Valid: true},
+		LeaseExpiresAt: sql.NullTime{Time: expiresAt, Valid: true},
+		UserID:         userID,
+		Now:            sql.NullTime{Time: now, Valid: true},
+		Limit:          int64(limit),
+	})
+	if err != nil {
+		return err
+	}
+
+	// RETURNING does not guarantee any ordering
+	slices.SortFunc(links, func(a, b repository.ClaimLinksRow) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	linksResponse := make([]Link, 0, len(links))
+
+	for _, link := range links {
+		linksResponse = append(linksResponse, Link{
+			ID:           link.ID,
+			URL:          link.Url,
+			Title:        link.Title,
+			Note:         link.Note.String,
+			BookmarkedAt: link.BookmarkedAt,
+			Tags:         splitTags(link.Tags.String),
+		})
+	}
+
+	if err := addHighlights(c.Request().Context(), s.repository, userID, linksResponse); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"lease_id":   leaseID,
+		"expires_at": expiresAt,
+		"links":      linksResponse,
+	})
+}
+
+// ackLinksHandler moves the links a consumer has confirmed it received to the
+// trash. Only links that still belong to the given lease are moved, so links
+// that were re-leased to another consumer after expiry are left alone.
+func (s *Server) ackLinksHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var ackLinksPayload struct {
+		LeaseID string  `json:"lease_id" validate:"required"`
+		IDs     []int64 `json:"ids" validate:"required,min=1"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&ackLinksPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(ackLinksPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var acknowledged int64
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		acknowledged, err = q.AckLinks(ctx, repository.AckLinksParams{
+			UserID:  userID,
+			LeaseID: sql.NullString{String: ackLinksPayload.LeaseID, Valid: true},
+			Ids:     ackLinksPayload.IDs,
+		})
+		if err != nil || acknowledged == 0 {
+			return err
+		}
+
+		return q.CompactPinnedLinks(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"acknowledged": acknowledged,
+		"success":      true,
+	})
+}
+
+const onConflictMerge = "merge"
+
+type newLink struct {
+	URL     string
+	Title   string
+	Note    string
+	Tags    []string
+	GroupID string
+	// BookmarkedAt overrides the time the link was saved, for imported links.
+	BookmarkedAt time.Time
+	// ReadAt marks imported links that were already read.
+	ReadAt time.Time
+}
+
+// createLink inserts a link unless the user already has one with the same
+// canonical URL. For duplicates it returns the id of the existing link, after
+// merging the new note and tags into it if onConflict is "merge". It must be
+// called with queries bound to a transaction.
+func createLink(ctx context.Context, q *repository.Queries, userID int64, link newLink, onConflict string) (int64, bool, error) {
+	canonicalURL := sql.NullString{String: canonicalurl.Canonicalize(link.URL), Valid: true}
+
+	existing, err := q.GetLinkByCanonicalURL(ctx, repository.GetLinkByCanonicalURLParams{
+		UserID:       userID,
+		CanonicalUrl: canonicalURL,
+	})
+	if err == nil {
+		return existing.ID, true, duplicateLink(ctx, q, userID, existing, link, onConflict)
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	row, err := q.CreateLink(ctx, repository.CreateLinkParams{
+		UserID:       userID,
+		Url:          link.URL,
+		Title:        link.Title,
+		Note:         sql.NullString{String: link.Note, Valid: link.Note != ""},
+		Domain:       linkDomain(link.URL),
+		CanonicalUrl: canonicalURL,
+		GroupID:      sql.NullString{String: link.GroupID, Valid: link.GroupID != ""},
+	})
+	if err != nil {
+		// A concurrent request saved the link since it was looked up
+		if strings.Contains(err.Error(), "UNIQUE constraint") {
+			existing, err := q.GetLinkByCanonicalURL(ctx, repository.GetLinkByCanonicalURLParams{
+				UserID:       userID,
+				CanonicalUrl: canonicalURL,
+			})
+			if err != nil {
+				return 0, false, err
+			}
+
+			return existing.ID, true, duplicateLink(ctx, q, userID, existing, link, onConflict)
+		}
+
+		return 0, false, err
+	}
+
+	if !link.BookmarkedAt.IsZero() {
+		err = q.SetLinkBookmarkedAt(ctx, repository.SetLinkBookmarkedAtParams{
+			BookmarkedAt: link.BookmarkedAt.UTC(),
+			ID:           row.ID,
+			UserID:       userID,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if !link.ReadAt.IsZero() {
+		_, err = q.MarkLinksRead(ctx, repository.MarkLinksReadParams{
+			ReadAt: sql.NullTime{Time: link.ReadAt.UTC(), Valid: true},
+			UserID: userID,
+			Ids:    []int64{row.ID},
+		})
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	return row.ID, false, setLinkTags(ctx, q, userID, row.ID, link.Tags)
+}
+
+// duplicateLink handles saving a link the user already has, merging into it
+// if onConflict is "merge".
+func duplicateLink(ctx context.Context, q *repository.Queries, userID int64, existing repository.GetLinkByCanonicalURLRow, link newLink, onConflict string) error {
+	if onConflict == onConflictMerge {
+		return mergeIntoLink(ctx, q, userID, existing, link)
+	}
+
+	return nil
+}
+
+// mergeIntoLink appends a new note to an existing link's note and adds the
+// new tags to its tags.
+func mergeIntoLink(ctx context.Context, q *repository.Queries, userID int64, existing repository.GetLinkByCanonicalURLRow, link newLink) error {
+	note := existing.Note.String
+	if link.Note != "" && !strings.Contains(note, link.Note) {
+		if note != "" {
+			note += "\n\n"
+		}
+		note += link.Note
+	}
+
+	_, err := q.UpdateLink(ctx, repository.UpdateLinkParams{
+		Note:   sql.NullString{String: note, Valid: note != ""},
+		ID:     existing.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	tags := normalizeTags(append(splitTags(existing.Tags.String), link.Tags...))
+
+	return setLinkTags(ctx, q, userID, existing.ID, tags)
+}
+
+func getLinkIDFromParam(c echo.Context) (int64, error) {
+	linkID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Link ID")
+	}
+
+	return linkID, nil
+}
+
+func toNullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: *value, Valid: true}
+}
+
+// newLinkResponse builds the response for a link that was read with all of
+// its columns.
+func newLinkResponse(link repository.GetLinkRow) Link {
+	return Link{
+		ID:             link.ID,
+		URL:            link.Url,
+		Title:          link.Title,
+		Note:           link.Note.String,
+		BookmarkedAt:   link.BookmarkedAt,
+		Tags:           splitTags(link.Tags.String),
+		ReadAt:         nullTimePtr(link.ReadAt),
+		ArchivedAt:     nullTimePtr(link.ArchivedAt),
+		Starred:        link.Starred,
+		PinnedPosition: nullInt64Ptr(link.PinnedPosition),
+	}
+}
+
+func nullTimePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Time
+}
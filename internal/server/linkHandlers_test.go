@@ -0,0 +1,305 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+type syncResponse struct {
+	Links      []Link `json:"links"`
+	NextCursor int64  `json:"next_cursor"`
+}
+
+func TestSyncCursorPastNewestLink(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "sync")
+
+	var apiToken struct {
+		Token string `json:"token"`
+	}
+	if code := testRequest(t, h, http.MethodPost, "/api/tokens", jwt, `{"name":"sync"}`, &apiToken); code != http.StatusCreated {
+		t.Fatalf("POST /api/tokens = %d", code)
+	}
+
+	for i := range 3 {
+		createTestLink(t, h, jwt, i)
+	}
+
+	var synced syncResponse
+
+	// A cursor past every link the token was sent confirms nothing
+	testRequest(t, h, http.MethodGet, "/api/links?since=1000", apiToken.Token, "", &synced)
+	if len(synced.Links) != 0 {
+		t.Errorf("GET /api/links?since=1000 returned %d links, expected 0", len(synced.Links))
+	}
+
+	testRequest(t, h, http.MethodGet, "/api/links?since=0", apiToken.Token, "", &synced)
+	if len(synced.Links) != 3 {
+		t.Fatalf("GET /api/links?since=0 returned %d links after a cursor past them, expected 3", len(synced.Links))
+	}
+
+	// Once the links were sent, confirming them prunes them
+	path := fmt.Sprintf("/api/links?since=%d", synced.NextCursor)
+	testRequest(t, h, http.MethodGet, path, apiToken.Token, "", &synced)
+
+	testRequest(t, h, http.MethodGet, "/api/links?since=0", apiToken.Token, "", &synced)
+	if len(synced.Links) != 0 {
+		t.Errorf("GET /api/links?since=0 returned %d links after confirming them, expected 0", len(synced.Links))
+	}
+
+	// Pruned links can still be restored from the trash
+	var trash []Link
+	testRequest(t, h, http.MethodGet, "/api/trash", jwt, "", &trash)
+	if len(trash) != 3 {
+		t.Errorf("GET /api/trash returned %d links after pruning, expected 3", len(trash))
+	}
+}
+
+func TestRestoredSyncedLinksStay(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "restore")
+
+	var apiToken struct {
+		Token string `json:"token"`
+	}
+	if code := testRequest(t, h, http.MethodPost, "/api/tokens", jwt, `{"name":"sync"}`, &apiToken); code != http.StatusCreated {
+		t.Fatalf("POST /api/tokens = %d", code)
+	}
+
+	pruned := createTestLink(t, h, jwt, 0)
+	acked := createTestLink(t, h, jwt, 1)
+
+	// The first link is pruned once the token syncs past it
+	var synced syncResponse
+	testRequest(t, h, http.MethodGet, "/api/links?since=0&limit=1", apiToken.Token, "", &synced)
+	testRequest(t, h, http.MethodGet, fmt.Sprintf("/api/links?since=%d", synced.NextCursor), apiToken.Token, "", &synced)
+
+	// The second is acknowledged by a consumer that claimed it
+	var claimed struct {
+		LeaseID string `json:"lease_id"`
+		Links   []Link `json:"links"`
+	}
+	testRequest(t, h, http.MethodPost, "/api/links/claim", jwt, "", &claimed)
+	if len(claimed.Links) != 1 || claimed.Links[0].ID != acked {
+		t.Fatalf("POST /api/links/claim returned %v, expected link %d", claimed.Links, acked)
+	}
+	body := fmt.Sprintf(`{"lease_id":%q,"ids":[%d]}`, claimed.LeaseID, acked)
+	if code := testRequest(t, h, http.MethodPost, "/api/links/ack", jwt, body, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/links/ack = %d", code)
+	}
+
+	for _, id := range []int64{pruned, acked} {
+		path := fmt.Sprintf("/api/trash/%d/restore", id)
+		if code := testRequest(t, h, http.MethodPost, path, jwt, "", nil); code != http.StatusOK {
+			t.Fatalf("POST %s = %d", path, code)
+		}
+	}
+
+	testRequest(t, h, http.MethodGet, fmt.Sprintf("/api/links?since=%d", synced.NextCursor), apiToken.Token, "", &synced)
+
+	claimed.Links = nil
+	testRequest(t, h, http.MethodPost, "/api/links/claim", jwt, "", &claimed)
+	if len(claimed.Links) != 0 {
+		t.Errorf("POST /api/links/claim returned %v after restoring, expected no links", claimed.Links)
+	}
+
+	for _, id := range []int64{pruned, acked} {
+		path := fmt.Sprintf("/api/links/%d", id)
+		if code := testRequest(t, h, http.MethodGet, path, jwt, "", nil); code != http.StatusOK {
+			t.Errorf("GET %s after restoring and syncing again = %d, expected 200", path, code)
+		}
+	}
+}
+
+func TestAckLinksKeepsPinsContiguous(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "ack")
+
+	var ids []int64
+	for i := range 3 {
+		id := createTestLink(t, h, jwt, i)
+		if code := testRequest(t, h, http.MethodPost, fmt.Sprintf("/api/links/%d/pin", id), jwt, `{}`, nil); code != http.StatusOK {
+			t.Fatalf("POST /api/links/%d/pin = %d", id, code)
+		}
+		ids = append(ids, id)
+	}
+
+	var claimed struct {
+		LeaseID string `json:"lease_id"`
+		Links   []Link `json:"links"`
+	}
+	testRequest(t, h, http.MethodPost, "/api/links/claim?limit=1", jwt, "", &claimed)
+	if len(claimed.Links) != 1 || claimed.Links[0].ID != ids[0] {
+		t.Fatalf("POST /api/links/claim returned %v, expected link %d", claimed.Links, ids[0])
+	}
+
+	body := fmt.Sprintf(`{"lease_id":%q,"ids":[%d]}`, claimed.LeaseID, ids[0])
+	if code := testRequest(t, h, http.MethodPost, "/api/links/ack", jwt, body, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/links/ack = %d", code)
+	}
+
+	for i, id := range ids[1:] {
+		var link Link
+		testRequest(t, h, http.MethodGet, fmt.Sprintf("/api/links/%d", id), jwt, "", &link)
+		if link.PinnedPosition == nil || *link.PinnedPosition != int64(i) {
+			t.Errorf("link %d pinned_position = %v, expected %d", id, link.PinnedPosition, i)
+		}
+	}
+}
+
+func TestDeleteLinkKeepsPinsContiguous(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "delete")
+
+	var ids []int64
+	for i := range 3 {
+		id := createTestLink(t, h, jwt, i)
+		if code := testRequest(t, h, http.MethodPost, fmt.Sprintf("/api/links/%d/pin", id), jwt, `{}`, nil); code != http.StatusOK {
+			t.Fatalf("POST /api/links/%d/pin = %d", id, code)
+		}
+		ids = append(ids, id)
+	}
+
+	path := fmt.Sprintf("/api/links/%d", ids[1])
+	if code := testRequest(t, h, http.MethodDelete, path, jwt, "", nil); code != http.StatusOK {
+		t.Fatalf("DELETE %s = %d", path, code)
+	}
+	if code := testRequest(t, h, http.MethodDelete, path, jwt, "", nil); code != http.StatusNotFound {
+		t.Errorf("deleting a trashed link = %d, expected 404", code)
+	}
+
+	for i, id := range []int64{ids[0], ids[2]} {
+		var link Link
+		testRequest(t, h, http.MethodGet, fmt.Sprintf("/api/links/%d", id), jwt, "", &link)
+		if link.PinnedPosition == nil || *link.PinnedPosition != int64(i) {
+			t.Errorf("link %d pinned_position = %v, expected %d", id, link.PinnedPosition, i)
+		}
+	}
+}
+
+func TestConcurrentSavesReturnDuplicate(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "concurrent")
+
+	const saves = 8
+
+	type result struct {
+		code int
+		body string
+	}
+	results := make(chan result, saves)
+
+	var wg sync.WaitGroup
+	for range saves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/links", strings.NewReader(`{"url":"https://example.com/","title":"Example"}`))
+			req.Header.Set(echo.HeaderContentType, "application/json")
+			req.Header.Set("Authorization", "Bearer "+jwt)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			results <- result{rec.Code, rec.Body.String()}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var created, duplicates int
+	for r := range results {
+		var response struct {
+			Result struct {
+				ID        int64 `json:"id"`
+				Duplicate bool  `json:"duplicate"`
+			} `json:"result"`
+		}
+
+		switch r.code {
+		case http.StatusCreated:
+			created++
+		case http.StatusOK:
+			if err := json.Unmarshal([]byte(r.body), &response); err != nil || !response.Result.Duplicate || response.Result.ID != 1 {
+				t.Errorf("duplicate save returned %s", r.body)
+			}
+			duplicates++
+		default:
+			t.Errorf("POST /api/links = %d: %s", r.code, r.body)
+		}
+	}
+
+	if created != 1 || duplicates != saves-1 {
+		t.Errorf("%d saves created the link and %d were duplicates, expected 1 and %d", created, duplicates, saves-1)
+	}
+}
+
+func TestDomainFilterIsLiteral(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "domains")
+
+	for _, rawURL := range []string{"https://example.com/", "https://blog.example.com/", "https://notexample.com/"} {
+		body := fmt.Sprintf(`{"url":%q,"title":"Example"}`, rawURL)
+		if code := testRequest(t, h, http.MethodPost, "/api/links", jwt, body, nil); code != http.StatusCreated {
+			t.Fatalf("POST /api/links = %d", code)
+		}
+	}
+
+	tests := []struct {
+		domain   string
+		expected []string
+	}{
+		{"example.com", []string{"https://blog.example.com/", "https://example.com/"}},
+		{"blog.example.com", []string{"https://blog.example.com/"}},
+		{"_xample.com", nil},
+		{"%.com", nil},
+	}
+
+	for _, tt := range tests {
+		var page struct {
+			Links []Link `json:"links"`
+		}
+		path := "/api/links?domain=" + url.QueryEscape(tt.domain)
+		if code := testRequest(t, h, http.MethodGet, path, jwt, "", &page); code != http.StatusOK {
+			t.Fatalf("GET %s = %d", path, code)
+		}
+
+		var urls []string
+		for _, link := range page.Links {
+			urls = append(urls, link.URL)
+		}
+		slices.Sort(urls)
+
+		if !slices.Equal(urls, tt.expected) {
+			t.Errorf("GET %s returned %v, expected %v", path, urls, tt.expected)
+		}
+	}
+}
+
+// createTestLink saves the link https://example.com/<n> and returns its ID.
+func createTestLink(t *testing.T, h http.Handler, jwt string, n int) int64 {
+	t.Helper()
+
+	var created struct {
+		Result struct {
+			ID int64 `json:"id"`
+		} `json:"result"`
+	}
+	body := fmt.Sprintf(`{"url":"https://example.com/%d","title":"Link %d"}`, n, n)
+	if code := testRequest(t, h, http.MethodPost, "/api/links", jwt, body, &created); code != http.StatusCreated {
+		t.Fatalf("POST /api/links = %d", code)
+	}
+
+	return created.Result.ID
+}
@@ -0,0 +1,386 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// Reading states of a link. Unread links are the reading list; archiving a
+// link takes it off the list whether or not it was read.
+const (
+	linkStateUnread   = "unread"
+	linkStateRead     = "read"
+	linkStateArchived = "archived"
+)
+
+const (
+	// maxLinkStateBatch is the most links POST /api/links/state updates at
+	// once.
+	maxLinkStateBatch = 500
+
+	// maxPinnedLinks is the most links a user can pin. All of them are
+	// returned on the first page of links.
+	maxPinnedLinks = 50
+)
+
+func (s *Server) setLinkStateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var setLinkStatePayload struct {
+		State string `json:"state" validate:"required,oneof=unread read archived"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&setLinkStatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(setLinkStatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		updated, err := setLinksState(ctx, q, userID, []int64{linkID}, setLinkStatePayload.State)
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return sql.ErrNoRows
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newLinkResponse(link))
+}
+
+// setLinksStateHandler sets the reading state of several links at once. Links
+// that don't exist are skipped, so updated can be lower than the number of
+// link IDs.
+func (s *Server) setLinksStateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var setLinksStatePayload struct {
+		LinkIDs []int64 `json:"link_ids" validate:"required,min=1"`
+		State   string  `json:"state" validate:"required,oneof=unread read archived"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&setLinksStatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(setLinksStatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if len(setLinksStatePayload.LinkIDs) > maxLinkStateBatch {
+		return echo.NewHTTPError(http.StatusBadRequest, "Too many link IDs")
+	}
+
+	updated, err := setLinksState(c.Request().Context(), s.repository, userID, setLinksStatePayload.LinkIDs, setLinksStatePayload.State)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+		"updated": updated,
+	})
+}
+
+// linkStatsHandler counts the user's links by reading state, for unread
+// badges that shouldn't have to list the links.
+func (s *Server) linkStatsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	stats, err := s.repository.GetLinkStats(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"total":    stats.Total,
+		"unread":   stats.Unread,
+		"read":     stats.Read,
+		"archived": stats.Archived,
+	})
+}
+
+// setLinksState moves links into a reading state and returns how many of them
+// were found.
+func setLinksState(ctx context.Context, q *repository.Queries, userID int64, linkIDs []int64, state string) (int64, error) {
+	now := sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+
+	switch state {
+	case linkStateRead:
+		return q.MarkLinksRead(ctx, repository.MarkLinksReadParams{
+			ReadAt: now,
+			UserID: userID,
+			Ids:    linkIDs,
+		})
+	case linkStateArchived:
+		return q.MarkLinksArchived(ctx, repository.MarkLinksArchivedParams{
+			ArchivedAt: now,
+			UserID:     userID,
+			Ids:        linkIDs,
+		})
+	default:
+		return q.MarkLinksUnread(ctx, repository.MarkLinksUnreadParams{
+			UserID: userID,
+			Ids:    linkIDs,
+		})
+	}
+}
+
+func (s *Server) starLinkHandler(c echo.Context) error {
+	return s.setLinkStarred(c, true)
+}
+
+func (s *Server) unstarLinkHandler(c echo.Context) error {
+	return s.setLinkStarred(c, false)
+}
+
+func (s *Server) setLinkStarred(c echo.Context, starred bool) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		updated, err := q.SetLinkStarred(ctx, repository.SetLinkStarredParams{
+			Starred: starred,
+			ID:      linkID,
+			UserID:  userID,
+		})
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return sql.ErrNoRows
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newLinkResponse(link))
+}
+
+// pinLinkHandler pins a link at a position among the pinned links, or after
+// them if no position is given. Pinning a pinned link moves it.
+func (s *Server) pinLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var pinLinkPayload struct {
+		Position *int64 `json:"position" validate:"omitempty,min=0"`
+	}
+
+	// The body is optional
+	err = json.NewDecoder(c.Request().Body).Decode(&pinLinkPayload)
+	if err != nil && err != io.EOF {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(pinLinkPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		existing, err := q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if existing.PinnedPosition.Valid {
+			if err := unpinLink(ctx, q, userID, existing); err != nil {
+				return err
+			}
+		}
+
+		pinned, err := q.GetPinnedLinkStats(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if pinned.Count >= maxPinnedLinks {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("At most %d links can be pinned", maxPinnedLinks))
+		}
+
+		position := pinned.NextPosition
+		if pinLinkPayload.Position != nil && *pinLinkPayload.Position < position {
+			position = *pinLinkPayload.Position
+		}
+
+		err = q.ShiftPinnedLinks(ctx, repository.ShiftPinnedLinksParams{
+			Delta:        1,
+			UserID:       userID,
+			FromPosition: position,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.SetLinkPinnedPosition(ctx, repository.SetLinkPinnedPositionParams{
+			PinnedPosition: sql.NullInt64{Int64: position, Valid: true},
+			ID:             linkID,
+			UserID:         userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newLinkResponse(link))
+}
+
+func (s *Server) unpinLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var link repository.GetLinkRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		existing, err := q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if existing.PinnedPosition.Valid {
+			if err := unpinLink(ctx, q, userID, existing); err != nil {
+				return err
+			}
+		}
+
+		link, err = q.GetLink(ctx, repository.GetLinkParams{
+			ID:     linkID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Link not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newLinkResponse(link))
+}
+
+// unpinLink unpins a pinned link and closes the gap it leaves.
+func unpinLink(ctx context.Context, q *repository.Queries, userID int64, link repository.GetLinkRow) error {
+	_, err := q.SetLinkPinnedPosition(ctx, repository.SetLinkPinnedPositionParams{
+		ID:     link.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.ShiftPinnedLinks(ctx, repository.ShiftPinnedLinksParams{
+		Delta:        -1,
+		UserID:       userID,
+		FromPosition: link.PinnedPosition.Int64 + 1,
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"os"
 
@@ -15,6 +16,7 @@ import (
 
 func (s *Server) RegisterRoutes() http.Handler {
 	e := echo.New()
+	e.IPExtractor = s.ipExtractor()
 	e.Use(prettylogger.Logger)
 	e.Use(middleware.Recover())
 
@@ -37,6 +39,23 @@ func (s *Server) RegisterRoutes() http.Handler {
 	e.POST("/signup", s.signupHandler)
 	e.POST("/signin", s.signinHandler)
 	e.GET("/me", s.meHandler)
+	e.POST("/auth/mfa", s.mfaHandler)
+	e.POST("/auth/refresh", s.refreshHandler)
+	e.POST("/auth/logout", s.logoutHandler, auth.GetMiddleware(s.repository))
+	e.POST("/auth/password/reset", s.resetPasswordHandler)
+	e.GET("/auth/email/verify", s.verifyEmailHandler)
+
+	// Admin routes
+	adminAuth := middleware.BasicAuth(func(username, password string, c echo.Context) (bool, error) {
+		adminUsername := os.Getenv("SQLITE_ADMIN_USERNAME")
+		if adminUsername == "" {
+			return false, nil
+		}
+		usernameMatches := subtle.ConstantTimeCompare([]byte(username), []byte(adminUsername)) == 1
+		passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(os.Getenv("SQLITE_ADMIN_PASSWORD"))) == 1
+		return usernameMatches && passwordMatches, nil
+	})
+	e.POST("/admin/users/:username/password-reset", s.createPasswordResetHandler, adminAuth)
 
 	// Backfill
 	backfillGroup := e.Group("/backfill")
@@ -57,10 +76,85 @@ func (s *Server) RegisterRoutes() http.Handler {
 	api.POST("/tokens", s.createTokenHandler)
 	api.DELETE("/tokens/:id", s.deleteTokenHandler)
 
+	// Session routes
+	api.GET("/sessions", s.listSessionsHandler)
+	api.DELETE("/sessions/:id", s.revokeSessionHandler)
+
+	// Account routes
+	api.GET("/account", s.getAccountHandler)
+	api.POST("/account/password", s.changePasswordHandler)
+	api.PUT("/account/email", s.setEmailHandler)
+	api.POST("/account/email/verification", s.resendEmailVerificationHandler)
+	api.GET("/account/2fa", s.twoFactorStatusHandler)
+	api.POST("/account/2fa/enroll", s.enrollTwoFactorHandler)
+	api.POST("/account/2fa/confirm", s.confirmTwoFactorHandler)
+	api.POST("/account/2fa/disable", s.disableTwoFactorHandler)
+	api.POST("/account/2fa/recovery-codes", s.regenerateRecoveryCodesHandler)
+
 	// Link routes
 	api.GET("/links", s.listLinksHandler)
 	api.POST("/links", s.createLinkHandler)
+	api.POST("/links/batch", s.createLinksBatchHandler)
 	api.POST("/links/clear", s.clearLinksHandler)
+	api.POST("/links/claim", s.claimLinksHandler)
+	api.POST("/links/ack", s.ackLinksHandler)
+	api.GET("/links/search", s.searchLinksHandler)
+	api.GET("/links/stats", s.linkStatsHandler)
+	api.POST("/links/state", s.setLinksStateHandler)
+	api.GET("/links/:id", s.getLinkHandler)
+	api.PATCH("/links/:id", s.updateLinkHandler)
+	api.DELETE("/links/:id", s.deleteLinkHandler)
+	api.POST("/links/:id/state", s.setLinkStateHandler)
+	api.POST("/links/:id/star", s.starLinkHandler)
+	api.DELETE("/links/:id/star", s.unstarLinkHandler)
+	api.POST("/links/:id/pin", s.pinLinkHandler)
+	api.DELETE("/links/:id/pin", s.unpinLinkHandler)
+
+	// Highlight routes
+	api.GET("/links/:id/highlights", s.listHighlightsHandler)
+	api.POST("/links/:id/highlights", s.createHighlightHandler)
+	api.GET("/links/:id/highlights/:highlight_id", s.getHighlightHandler)
+	api.PATCH("/links/:id/highlights/:highlight_id", s.updateHighlightHandler)
+	api.DELETE("/links/:id/highlights/:highlight_id", s.deleteHighlightHandler)
+
+	// Trash routes
+	api.GET("/trash", s.listTrashHandler)
+	api.DELETE("/trash", s.emptyTrashHandler)
+	api.POST("/trash/:id/restore", s.restoreLinkHandler)
+	api.DELETE("/trash/:id", s.purgeLinkHandler)
+
+	// Collection routes
+	api.GET("/collections", s.listCollectionsHandler)
+	api.POST("/collections", s.createCollectionHandler)
+	api.GET("/collections/:id", s.getCollectionHandler)
+	api.PATCH("/collections/:id", s.updateCollectionHandler)
+	api.DELETE("/collections/:id", s.deleteCollectionHandler)
+	api.POST("/collections/:id/links", s.addCollectionLinksHandler)
+	api.DELETE("/collections/:id/links/:link_id", s.removeCollectionLinkHandler)
+	api.POST("/collections/:id/links/:link_id/move", s.moveCollectionLinkHandler)
+
+	// Tag routes
+	api.GET("/tags", s.listTagsHandler)
+	api.GET("/tags/tree", s.tagTreeHandler)
+	api.POST("/tags/merge", s.mergeTagsHandler)
+	api.POST("/tags/rename", s.renameTagPathHandler)
+	api.PATCH("/tags/:id", s.renameTagHandler)
+	api.DELETE("/tags/:id", s.deleteTagHandler)
+
+	// Import routes
+	api.POST("/import/:format", s.importHandler)
+
+	// Export routes
+	api.GET("/export", s.exportHandler, middleware.Gzip())
+	api.GET("/export/netscape", s.exportNetscapeHandler)
+	api.GET("/export/markdown", s.exportMarkdownHandler)
+
+	// Template routes
+	api.GET("/templates", s.listTemplatesHandler)
+	api.POST("/templates", s.createTemplateHandler)
+	api.GET("/templates/:id", s.getTemplateHandler)
+	api.PATCH("/templates/:id", s.updateTemplateHandler)
+	api.DELETE("/templates/:id", s.deleteTemplateHandler)
 
 	return e
 }
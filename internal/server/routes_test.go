@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"github.com/labstack/echo/v4"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -37,3 +38,42 @@ func TestHandler(t *testing.T) {
 		return
 	}
 }
+
+func TestIPExtractor(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    []*net.IPNet
+		remoteAddr string
+		expected   string
+	}{
+		{"no trusted proxies", nil, "10.1.2.3:1234", "10.1.2.3"},
+		{"trusted proxy", proxies, "10.1.2.3:1234", "203.0.113.7"},
+		{"trusted proxy address", proxies, "192.168.1.1:1234", "203.0.113.7"},
+		{"untrusted proxy", proxies, "192.168.1.2:1234", "192.168.1.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{trustedProxies: tt.proxies}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+
+			if ip := s.ipExtractor()(req); ip != tt.expected {
+				t.Errorf("client IP = %q, expected %q", ip, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	if _, err := parseTrustedProxies("10.0.0.0/8,proxy"); err == nil {
+		t.Error("parseTrustedProxies() accepted a host name")
+	}
+}
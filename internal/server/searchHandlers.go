@@ -0,0 +1,96 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"linkstowr/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type SearchResult struct {
+	Link
+	TitleSnippet string  `json:"title_snippet"`
+	NoteSnippet  string  `json:"note_snippet"`
+	Rank         float64 `json:"rank"`
+}
+
+func (s *Server) searchLinksHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	query := buildSearchQuery(c.QueryParam("q"))
+	if query == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Search query is required")
+	}
+
+	limit := defaultSearchLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid limit")
+		}
+		limit = min(limit, maxSearchLimit)
+	}
+
+	offset := 0
+	if offsetParam := c.QueryParam("offset"); offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid offset")
+		}
+	}
+
+	rows, err := s.repository.SearchLinks(c.Request().Context(), repository.SearchLinksParams{
+		Query:  query,
+		UserID: userID,
+		Limit:  int64(limit),
+		Offset: int64(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+
+	for _, row := range rows {
+		results = append(results, SearchResult{
+			Link: Link{
+				ID:           row.ID,
+				URL:          row.Url,
+				Title:        row.Title,
+				Note:         row.Note.String,
+				BookmarkedAt: row.BookmarkedAt,
+				Tags:         splitTags(row.Tags.String),
+			},
+			TitleSnippet: row.TitleSnippet,
+			NoteSnippet:  row.NoteSnippet,
+			Rank:         row.Rank,
+		})
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"results": results,
+	})
+}
+
+// buildSearchQuery turns free-form user input into an FTS5 query that
+// prefix-matches every term. Terms are quoted so that FTS5 operators and
+// punctuation in the input are matched literally.
+func buildSearchQuery(input string) string {
+	terms := strings.Fields(input)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,19 @@
+package server
+
+import "testing"
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"   ":              "",
+		"golang":           `"golang"*`,
+		" go  testing ":    `"go"* "testing"*`,
+		`say "hi" OR NEAR`: `"say"* """hi"""* "OR"* "NEAR"*`,
+	}
+
+	for input, expected := range tests {
+		if actual := buildSearchQuery(input); actual != expected {
+			t.Errorf("buildSearchQuery(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package server
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/labstack/echo/v4"
 
+	"linkstowr/internal/canonicalurl"
 	"linkstowr/internal/database"
+	"linkstowr/internal/mail"
+	"linkstowr/internal/notify"
 	"linkstowr/internal/repository"
 )
 
@@ -20,23 +28,111 @@ type Server struct {
 	db database.Service
 
 	repository *repository.Queries
+
+	// linkRetention is how long links that some, but not all, sync consumers
+	// have received are kept around for the remaining consumers.
+	linkRetention time.Duration
+
+	// linkBatchLimit is the most links POST /api/links/batch accepts at once.
+	linkBatchLimit int
+
+	// trashRetention is how long deleted links stay in the trash before they
+	// are purged.
+	trashRetention time.Duration
+
+	// refreshTokenTTL is how long a refresh token can be exchanged for new
+	// tokens. Every exchange starts the clock again.
+	refreshTokenTTL time.Duration
+
+	// notifier delivers password reset tokens to users.
+	notifier notify.Notifier
+
+	// mailer sends email address verification links.
+	mailer mail.Mailer
+
+	// publicURL is the URL the server is reached at, which links in emails
+	// point to.
+	publicURL string
+
+	// trustedProxies are the networks of reverse proxies whose
+	// X-Forwarded-For header gives the client IP. Without any, the IP of the
+	// connection is used.
+	trustedProxies []*net.IPNet
 }
 
+const (
+	defaultLinkRetentionDays  = 30
+	defaultLinkBatchLimit     = 100
+	defaultTrashRetentionDays = 30
+	defaultRefreshTokenDays   = 30
+)
+
 func NewServer() *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
+	retentionDays, err := strconv.Atoi(os.Getenv("LINK_RETENTION_DAYS"))
+	if err != nil || retentionDays <= 0 {
+		retentionDays = defaultLinkRetentionDays
+	}
+	batchLimit, err := strconv.Atoi(os.Getenv("LINK_BATCH_LIMIT"))
+	if err != nil || batchLimit <= 0 {
+		batchLimit = defaultLinkBatchLimit
+	}
+	trashRetentionDays, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || trashRetentionDays <= 0 {
+		trashRetentionDays = defaultTrashRetentionDays
+	}
+	refreshTokenDays, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_DAYS"))
+	if err != nil || refreshTokenDays <= 0 {
+		refreshTokenDays = defaultRefreshTokenDays
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("http://localhost:%d", port)
+	}
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	mailer, err := mail.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	notifier, err := notify.New(mailer)
+	if err != nil {
+		log.Fatal(err)
+	}
 	db := database.New()
 	if err := db.RunMigrations(); err != nil {
 		log.Fatal(err)
 	}
 
+	if err := backfillCanonicalURLs(context.Background(), repository.New(db.GetDB())); err != nil {
+		log.Fatal(err)
+	}
+
 	NewServer := &Server{
 		port: port,
 
 		db: database.New(),
 
 		repository: repository.New(db.GetDB()),
+
+		linkRetention:   time.Duration(retentionDays) * 24 * time.Hour,
+		linkBatchLimit:  batchLimit,
+		trashRetention:  time.Duration(trashRetentionDays) * 24 * time.Hour,
+		refreshTokenTTL: time.Duration(refreshTokenDays) * 24 * time.Hour,
+
+		notifier:       notifier,
+		mailer:         mailer,
+		publicURL:      publicURL,
+		trustedProxies: trustedProxies,
 	}
 
+	// The purges stop once the server is shut down
+	purgeCtx, stopPurges := context.WithCancel(context.Background())
+	go NewServer.purgeTrash(purgeCtx)
+	go NewServer.purgeSessions(purgeCtx)
+
 	// Declare Server config
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", NewServer.port),
@@ -45,6 +141,104 @@ func NewServer() *http.Server {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	server.RegisterOnShutdown(stopPurges)
 
 	return server
 }
+
+// parseTrustedProxies parses a comma separated list of IP addresses and CIDR
+// ranges.
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", field)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			field = fmt.Sprintf("%s/%d", field, bits)
+		}
+
+		_, ipNet, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", field, err)
+		}
+		proxies = append(proxies, ipNet)
+	}
+
+	return proxies, nil
+}
+
+// ipExtractor returns how echo finds the client IP. Headers naming it are
+// only believed from trusted proxies, since any client can send them.
+func (s *Server) ipExtractor() echo.IPExtractor {
+	if len(s.trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range s.trustedProxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// backfillCanonicalURLs gives links saved before URLs were canonicalized a
+// canonical URL. Links that turn out to duplicate an earlier link keep a NULL
+// canonical URL, and are marked so that they aren't retried on every startup.
+func backfillCanonicalURLs(ctx context.Context, q *repository.Queries) error {
+	links, err := q.ListLinksWithoutCanonicalURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		updated, err := q.SetLinkCanonicalURL(ctx, repository.SetLinkCanonicalURLParams{
+			CanonicalUrl: sql.NullString{String: canonicalurl.Canonicalize(link.Url), Valid: true},
+			ID:           link.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if updated == 0 {
+			if err := q.MarkLinkCanonicalURLConflict(ctx, link.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// withTx runs fn against a repository bound to a new transaction, committing
+// it if fn succeeds and rolling it back otherwise.
+func (s *Server) withTx(ctx context.Context, fn func(q *repository.Queries) error) error {
+	tx, err := s.db.GetDB().BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(s.repository.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
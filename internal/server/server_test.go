@@ -0,0 +1,142 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/labstack/echo/v4"
+
+	"linkstowr/internal/database"
+	"linkstowr/internal/mail"
+	"linkstowr/internal/notify"
+	"linkstowr/internal/repository"
+)
+
+// testDB is a database.Service for a database the test has migrated.
+type testDB struct {
+	db *sql.DB
+}
+
+func (d testDB) GetDB() *sql.DB            { return d.db }
+func (d testDB) Health() map[string]string { return nil }
+func (d testDB) Close() error              { return d.db.Close() }
+func (d testDB) RunMigrations() error      { return nil }
+
+// newTestServer returns a server backed by a new, migrated database.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	t.Setenv("JWT_ENCODING_SECRET", "test")
+
+	db, err := sql.Open("sqlite3", database.DSN(t.TempDir()+"/test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://../../db/migrations", "sqlite3", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Server{
+		db:              testDB{db},
+		repository:      repository.New(db),
+		linkRetention:   24 * time.Hour,
+		linkBatchLimit:  defaultLinkBatchLimit,
+		trashRetention:  24 * time.Hour,
+		refreshTokenTTL: time.Hour,
+		notifier:        notify.NewLogNotifier(&strings.Builder{}),
+		mailer:          mail.NewLogMailer(&strings.Builder{}),
+		publicURL:       "http://localhost",
+	}
+}
+
+// testRequest sends a request to the server's routes and decodes the JSON
+// response into out, if it isn't nil. Tokens starting with the API token
+// prefix are sent as API tokens, others as JWTs.
+func testRequest(t *testing.T, h http.Handler, method, path, token, body string, out any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "application/json")
+	if strings.HasPrefix(token, "lshelf") {
+		req.Header.Set("X-Api-Token", token)
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if out != nil && rec.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+// signupTestUser signs up a user with password "password" and returns their
+// JWT.
+func signupTestUser(t *testing.T, h http.Handler, username string) string {
+	t.Helper()
+
+	var tokens struct {
+		Token string `json:"token"`
+	}
+
+	body := `{"username":"` + username + `","password":"password","password_confirm":"password"}`
+	if code := testRequest(t, h, http.MethodPost, "/signup", "", body, &tokens); code != http.StatusCreated {
+		t.Fatalf("POST /signup = %d", code)
+	}
+
+	return tokens.Token
+}
+
+func TestBackfillCanonicalURLsMarksConflicts(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	signupTestUser(t, h, "backfill")
+
+	// Links saved before URLs were canonicalized, the second a duplicate
+	for _, url := range []string{"https://example.com/a", "https://EXAMPLE.com/a"} {
+		_, err := s.db.GetDB().Exec("INSERT INTO links (url, title, user_id) VALUES (?, 'Example', 1)", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	if err := backfillCanonicalURLs(ctx, s.repository); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := s.repository.ListLinksWithoutCanonicalURL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 0 {
+		t.Errorf("links left for the next backfill = %v, expected none", links)
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"linkstowr/internal/auth"
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// sessionPurgeInterval is how often expired refresh tokens are purged.
+const sessionPurgeInterval = time.Hour
+
+// refreshHandler exchanges a refresh token for a new access token and a new
+// refresh token. Each refresh token can be exchanged once; presenting one
+// again means it leaked, so every token of its family is revoked.
+func (s *Server) refreshHandler(c echo.Context) error {
+	var refreshPayload struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	err := json.NewDecoder(c.Request().Body).Decode(&refreshPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(refreshPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var tokens echo.Map
+	var reused bool
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		session, err := q.GetSessionByRefreshTokenHash(ctx, auth.HashSecretToken(refreshPayload.RefreshToken))
+		if err != nil {
+			return err
+		}
+
+		if session.RevokedAt.Valid || !now.Before(session.ExpiresAt) {
+			return sql.ErrNoRows
+		}
+
+		rotated, err := q.RotateSession(ctx, repository.RotateSessionParams{
+			RotatedAt: sql.NullTime{Time: now, Valid: true},
+			ID:        session.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if rotated == 0 {
+			// The revocation has to be committed, so this isn't an error
+			reused = true
+			_, err := q.RevokeSessionFamily(ctx, repository.RevokeSessionFamilyParams{
+				RevokedAt: sql.NullTime{Time: now, Valid: true},
+				FamilyID:  session.FamilyID,
+				UserID:    session.UserID,
+			})
+			return err
+		}
+
+		user, err := q.GetUserByID(ctx, session.UserID)
+		if err != nil {
+			return err
+		}
+
+		tokens, err = s.issueTokens(c, q, user.ID, user.Username, session.FamilyID)
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid refresh token")
+		}
+
+		return err
+	}
+
+	if reused {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token has already been used")
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// logoutHandler revokes the session of the JWT used to sign out, so neither
+// its access token nor its refresh token are accepted any more.
+func (s *Server) logoutHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	sessionID, ok := getSessionIDFromContext(c)
+	if !ok {
+		if _, ok := getTokenIDFromContext(c); ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "API tokens can't be logged out, delete them instead")
+		}
+
+		return echo.NewHTTPError(http.StatusBadRequest, "Tokens issued before sessions existed can't be logged out, they expire within a day")
+	}
+
+	_, err = s.repository.RevokeSessionFamily(c.Request().Context(), repository.RevokeSessionFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		FamilyID:  sessionID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// Session is a signed-in device. It lasts until it is revoked or its refresh
+// token expires.
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+func (s *Server) listSessionsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	sessions, err := s.repository.ListSessions(c.Request().Context(), repository.ListSessionsParams{
+		UserID: userID,
+		Now:    time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	currentSessionID, _ := getSessionIDFromContext(c)
+
+	sessionsResponse := make([]Session, 0, len(sessions))
+
+	for _, session := range sessions {
+		sessionsResponse = append(sessionsResponse, Session{
+			ID:         session.FamilyID,
+			UserAgent:  session.UserAgent.String,
+			IPAddress:  session.IpAddress.String,
+			LastSeenAt: nullTimePtr(session.LastSeenAt),
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.FamilyID == currentSessionID,
+		})
+	}
+
+	return c.JSON(http.StatusOK, sessionsResponse)
+}
+
+// revokeSessionHandler signs a device out, such as a lost laptop. Its access
+// token stops working right away.
+func (s *Server) revokeSessionHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	revoked, err := s.repository.RevokeSessionFamily(c.Request().Context(), repository.RevokeSessionFamilyParams{
+		RevokedAt: sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+		FamilyID:  c.Param("id"),
+		UserID:    userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if revoked == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// startSession starts a new refresh token family for a user who just signed
+// in and returns their tokens.
+func (s *Server) startSession(c echo.Context, userID int64, username string) (echo.Map, error) {
+	familyID, err := auth.NewRandomID()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(c, s.repository, userID, username, familyID)
+}
+
+// issueTokens signs an access token and stores a new refresh token in the
+// family, along with the device it was issued to.
+func (s *Server) issueTokens(c echo.Context, q *repository.Queries, userID int64, username string, familyID string) (echo.Map, error) {
+	refreshToken, refreshTokenHash, err := auth.NewSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err = q.CreateSession(c.Request().Context(), repository.CreateSessionParams{
+		UserID:           userID,
+		FamilyID:         familyID,
+		RefreshTokenHash: refreshTokenHash,
+		ExpiresAt:        now.Add(s.refreshTokenTTL),
+		UserAgent:        sql.NullString{String: c.Request().UserAgent(), Valid: true},
+		IpAddress:        sql.NullString{String: c.RealIP(), Valid: true},
+		LastSeenAt:       sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := auth.GenerateJWT(userID, username, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return echo.Map{
+		"id":            userID,
+		"username":      username,
+		"token":         token,
+		"expires_in":    int64(auth.AccessTokenDuration.Seconds()),
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+// purgeSessions deletes expired refresh tokens, password reset tokens and MFA
+// tokens every sessionPurgeInterval until ctx is done. Rotated refresh tokens
+// are kept until they expire so that reusing them is still noticed.
+func (s *Server) purgeSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Unix()
+		if _, err := s.repository.PurgeExpiredSessions(ctx, cutoff); err != nil {
+			log.Printf("Failed to purge sessions: %v", err)
+		}
+		if _, err := s.repository.PurgeExpiredPasswordResetTokens(ctx, cutoff); err != nil {
+			log.Printf("Failed to purge password reset tokens: %v", err)
+		}
+		if _, err := s.repository.PurgeExpiredMFAChallenges(ctx, cutoff); err != nil {
+			log.Printf("Failed to purge MFA tokens: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getSessionIDFromContext returns the ID of the session whose JWT
+// authenticated the request. It reports false for requests authenticated with
+// an API token or with a JWT issued before sessions existed.
+func getSessionIDFromContext(c echo.Context) (string, bool) {
+	sessionID, ok := c.Get("sessionID").(string)
+
+	return sessionID, ok
+}
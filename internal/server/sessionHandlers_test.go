@@ -0,0 +1,115 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"linkstowr/internal/auth"
+)
+
+func TestTokenWithoutSessionAccepted(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	signupTestUser(t, h, "legacy")
+
+	// Tokens issued before sessions existed have no jti
+	jwt, err := auth.GenerateJWT(1, "legacy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := testRequest(t, h, http.MethodGet, "/api/account", jwt, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/account with a token issued before sessions = %d, expected 200", code)
+	}
+	if code := testRequest(t, h, http.MethodPost, "/auth/logout", jwt, "", nil); code != http.StatusBadRequest {
+		t.Errorf("logging out a token issued before sessions = %d, expected 400", code)
+	}
+
+	// Logging out fails rather than claiming to sign out a token that stays
+	// valid
+	var account Account
+	if code := testRequest(t, h, http.MethodGet, "/api/account", jwt, "", &account); code != http.StatusOK || account.Username != "legacy" {
+		t.Errorf("GET /api/account after logging out = %d, expected 200", code)
+	}
+}
+
+// sessionTokens is the response of signing in or refreshing.
+type sessionTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signupTestSession signs up a user and returns their first tokens.
+func signupTestSession(t *testing.T, h http.Handler, username string) sessionTokens {
+	t.Helper()
+
+	var tokens sessionTokens
+	body := `{"username":"` + username + `","password":"password","password_confirm":"password"}`
+	if code := testRequest(t, h, http.MethodPost, "/signup", "", body, &tokens); code != http.StatusCreated {
+		t.Fatalf("POST /signup = %d", code)
+	}
+
+	return tokens
+}
+
+// refresh exchanges a refresh token, returning the status code and the new
+// tokens.
+func refresh(t *testing.T, h http.Handler, refreshToken string) (int, sessionTokens) {
+	t.Helper()
+
+	var tokens sessionTokens
+	code := testRequest(t, h, http.MethodPost, "/auth/refresh", "", `{"refresh_token":"`+refreshToken+`"}`, &tokens)
+
+	return code, tokens
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	first := signupTestSession(t, h, "rotate")
+
+	code, second := refresh(t, h, first.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("POST /auth/refresh = %d, expected 200", code)
+	}
+	if second.Token == "" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("POST /auth/refresh returned %+v, expected a new pair of tokens", second)
+	}
+	if code := testRequest(t, h, http.MethodGet, "/api/account", second.Token, "", nil); code != http.StatusOK {
+		t.Errorf("GET /api/account with the new access token = %d, expected 200", code)
+	}
+
+	code, third := refresh(t, h, second.RefreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("POST /auth/refresh with the new refresh token = %d, expected 200", code)
+	}
+
+	// Presenting a used refresh token again means it leaked
+	if code, _ := refresh(t, h, first.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("reusing a refresh token = %d, expected 401", code)
+	}
+
+	// The reuse revokes the whole session, tokens issued since included
+	if code, _ := refresh(t, h, third.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("POST /auth/refresh after a reuse = %d, expected 401", code)
+	}
+	if code := testRequest(t, h, http.MethodGet, "/api/account", third.Token, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/account after a reuse = %d, expected 401", code)
+	}
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+	s := newTestServer(t)
+	h := s.RegisterRoutes()
+	tokens := signupTestSession(t, h, "expired")
+
+	_, err := s.db.GetDB().Exec("UPDATE sessions SET expires_at = ?", time.Now().UTC().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := refresh(t, h, tokens.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("POST /auth/refresh with an expired refresh token = %d, expected 401", code)
+	}
+}
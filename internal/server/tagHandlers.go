@@ -0,0 +1,410 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type Tag struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	LinkCount  int64      `json:"link_count"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (s *Server) listTagsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	tags, err := s.repository.ListTagsWithCounts(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	tagsResponse := make([]Tag, 0, len(tags))
+
+	for _, tag := range tags {
+		tagsResponse = append(tagsResponse, Tag{
+			ID:         tag.ID,
+			Name:       tag.Name,
+			LinkCount:  tag.LinkCount,
+			LastUsedAt: unixToTime(tag.LastUsedAt),
+		})
+	}
+
+	return c.JSON(http.StatusOK, tagsResponse)
+}
+
+// tagTreeHandler returns the user's tags as a hierarchy of nested tags.
+func (s *Server) tagTreeHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	ctx := c.Request().Context()
+
+	tags, err := s.repository.ListTagsWithCounts(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tagLinks, err := s.repository.ListTagLinks(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, buildTagTree(tags, tagLinks))
+}
+
+// renameTagHandler renames a tag, along with all of its nested tags, on all of
+// the user's links. Renaming a tag to the name of another existing tag merges
+// the two.
+func (s *Server) renameTagHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	tagID, err := getTagIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var renameTagPayload struct {
+		Name string `json:"name" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&renameTagPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(renameTagPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	name, err := parseTagName(renameTagPayload.Name)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var resultID int64
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		tag, err := q.GetTag(ctx, repository.GetTagParams{
+			ID:     tagID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		resultID, err = renameTagTree(ctx, q, userID, tag.Name, name)
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"id":   resultID,
+		"name": name,
+	})
+}
+
+// renameTagPathHandler renames a nested tag by name. Unlike renameTagHandler
+// it also works on levels of the hierarchy that only exist as the parent of
+// other tags.
+func (s *Server) renameTagPathHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var renameTagPathPayload struct {
+		From string `json:"from" validate:"required"`
+		To   string `json:"to" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&renameTagPathPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(renameTagPathPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	from, err := parseTagName(renameTagPathPayload.From)
+	if err != nil {
+		return err
+	}
+
+	to, err := parseTagName(renameTagPathPayload.To)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		_, err := renameTagTree(ctx, q, userID, from, to)
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"name": to,
+	})
+}
+
+// mergeTagsHandler moves every link tagged with one of the source tags onto
+// the target tag, creating it if needed, and deletes the source tags.
+func (s *Server) mergeTagsHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var mergeTagsPayload struct {
+		SourceIDs []int64 `json:"source_ids" validate:"required,min=1"`
+		Target    string  `json:"target" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&mergeTagsPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(mergeTagsPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	target, err := parseTagName(mergeTagsPayload.Target)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var targetID int64
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		targetID, err = q.UpsertTag(ctx, repository.UpsertTagParams{
+			UserID: userID,
+			Name:   target,
+		})
+		if err != nil {
+			return err
+		}
+
+		return mergeTags(ctx, q, userID, mergeTagsPayload.SourceIDs, targetID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"id":   targetID,
+		"name": target,
+	})
+}
+
+// deleteTagHandler removes a tag from all of the user's links, leaving the
+// links themselves in place.
+func (s *Server) deleteTagHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	tagID, err := getTagIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+
+	var deleted int64
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		deleted, err = q.DeleteTag(ctx, repository.DeleteTagParams{
+			ID:     tagID,
+			UserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// renameTagTree renames the tag from, and every tag nested under it, to live
+// under to instead. Tags that end up with the name of an existing tag are
+// merged into it. It returns the id of the tag now named to, which is zero if
+// from only existed as the parent of other tags, and sql.ErrNoRows if the
+// user has no tags under from.
+func renameTagTree(ctx context.Context, q *repository.Queries, userID int64, from, to string) (int64, error) {
+	if strings.HasPrefix(to, from+"/") {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Cannot move a tag under itself")
+	}
+
+	tags, err := q.ListTagDescendants(ctx, repository.ListTagDescendantsParams{
+		UserID: userID,
+		Name:   from,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(tags) == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	var resultID int64
+	for _, tag := range tags {
+		tagID, err := renameTag(ctx, q, userID, tag.ID, to+strings.TrimPrefix(tag.Name, from))
+		if err != nil {
+			return 0, err
+		}
+
+		if tag.Name == from {
+			resultID = tagID
+		}
+	}
+
+	return resultID, nil
+}
+
+// renameTag renames a single tag, merging it into the existing tag of that
+// name if there is one. It returns the id of the tag now holding the name.
+func renameTag(ctx context.Context, q *repository.Queries, userID, tagID int64, name string) (int64, error) {
+	existing, err := q.GetTagByName(ctx, repository.GetTagByNameParams{
+		UserID: userID,
+		Name:   name,
+	})
+	if err == nil {
+		if existing.ID == tagID {
+			return tagID, nil
+		}
+
+		return existing.ID, mergeTags(ctx, q, userID, []int64{tagID}, existing.ID)
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = q.RenameTag(ctx, repository.RenameTagParams{
+		Name:   name,
+		ID:     tagID,
+		UserID: userID,
+	})
+
+	return tagID, err
+}
+
+// mergeTags moves the links of each source tag onto the target tag and then
+// deletes the source tags. It returns sql.ErrNoRows if a source tag does not
+// belong to the user.
+func mergeTags(ctx context.Context, q *repository.Queries, userID int64, sourceIDs []int64, targetID int64) error {
+	for _, sourceID := range sourceIDs {
+		if sourceID == targetID {
+			continue
+		}
+
+		_, err := q.GetTag(ctx, repository.GetTagParams{
+			ID:     sourceID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.MoveLinkTags(ctx, repository.MoveLinkTagsParams{
+			TargetTagID: targetID,
+			SourceTagID: sourceID,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = q.DeleteTag(ctx, repository.DeleteTagParams{
+			ID:     sourceID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseTagName normalizes a tag name the same way link tags are normalized,
+// rejecting names that do not make up exactly one tag.
+func parseTagName(name string) (string, error) {
+	tags := normalizeTags([]string{name})
+	if len(tags) != 1 {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invalid tag name")
+	}
+
+	return tags[0], nil
+}
+
+func getTagIDFromParam(c echo.Context) (int64, error) {
+	tagID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Tag ID")
+	}
+
+	return tagID, nil
+}
+
+func unixToTime(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	t := time.Unix(value.Int64, 0).UTC()
+	return &t
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"linkstowr/internal/repository"
+)
+
+// TagList accepts tags either as a JSON array or, for clients that still send
+// the old format, as a single comma-separated string.
+type TagList []string
+
+func (t *TagList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = normalizeTags(list)
+		return nil
+	}
+
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+
+	*t = normalizeTags([]string{legacy})
+	return nil
+}
+
+// normalizeTags lowercases tags, strips Obsidian-style leading '#'s and drops
+// empty and duplicate tags. Commas always separate tags, since links.tags
+// stores them comma-separated. Slashes separate the levels of nested tags
+// and empty levels are dropped, so "#Dev//Go/" becomes "dev/go".
+func normalizeTags(raw []string) []string {
+	tags := make([]string, 0, len(raw))
+	seen := make(map[string]bool)
+
+	for _, value := range raw {
+		for _, tag := range strings.Split(value, ",") {
+			tag = normalizeTagPath(strings.ToLower(strings.Trim(tag, " \t#")))
+			if tag == "" || seen[tag] {
+				continue
+			}
+
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func normalizeTagPath(tag string) string {
+	segments := strings.Split(tag, "/")
+	kept := segments[:0]
+
+	for _, segment := range segments {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			kept = append(kept, segment)
+		}
+	}
+
+	return strings.Join(kept, "/")
+}
+
+// splitTags reads the comma-separated copy of a link's tags kept in
+// links.tags.
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+
+	return strings.Split(tags, ",")
+}
+
+// setLinkTags replaces the tags of a link. It must be called with queries
+// bound to a transaction, after the link's ownership has been checked.
+func setLinkTags(ctx context.Context, q *repository.Queries, userID, linkID int64, tags []string) error {
+	if err := q.ClearLinkTags(ctx, linkID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tagID, err := q.UpsertTag(ctx, repository.UpsertTagParams{
+			UserID: userID,
+			Name:   tag,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.AddLinkTag(ctx, repository.AddLinkTagParams{
+			LinkID: linkID,
+			TagID:  tagID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TagNode is a level in the hierarchy of nested tags. Levels that only exist
+// as the parent of other tags have no ID.
+type TagNode struct {
+	ID   *int64 `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// LinkCount counts the links tagged with exactly this tag, TotalCount the
+	// distinct links tagged with this tag or any of its descendants.
+	LinkCount  int64      `json:"link_count"`
+	TotalCount int64      `json:"total_count"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Children   []*TagNode `json:"children"`
+
+	linkIDs map[int64]bool
+}
+
+// buildTagTree arranges a user's tags into a tree, rolling link counts and
+// last-used times up to every ancestor.
+func buildTagTree(tags []repository.ListTagsWithCountsRow, tagLinks []repository.ListTagLinksRow) []*TagNode {
+	roots := make([]*TagNode, 0)
+	nodes := make(map[string]*TagNode)
+
+	// node returns the node for path, creating it and its ancestors as needed
+	var node func(path string) *TagNode
+	node = func(path string) *TagNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+
+		n := &TagNode{
+			Name:     path,
+			Path:     path,
+			Children: make([]*TagNode, 0),
+			linkIDs:  make(map[int64]bool),
+		}
+		nodes[path] = n
+
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			n.Name = path[i+1:]
+			parent := node(path[:i])
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+
+		return n
+	}
+
+	for _, tag := range tags {
+		n := node(tag.Name)
+		n.ID = &tag.ID
+		n.LinkCount = tag.LinkCount
+
+		lastUsedAt := unixToTime(tag.LastUsedAt)
+		if lastUsedAt == nil {
+			continue
+		}
+
+		for _, path := range tagLineage(tag.Name) {
+			ancestor := nodes[path]
+			if ancestor.LastUsedAt == nil || lastUsedAt.After(*ancestor.LastUsedAt) {
+				ancestor.LastUsedAt = lastUsedAt
+			}
+		}
+	}
+
+	for _, tagLink := range tagLinks {
+		for _, path := range tagLineage(tagLink.Name) {
+			node(path).linkIDs[tagLink.LinkID] = true
+		}
+	}
+
+	for _, n := range nodes {
+		n.TotalCount = int64(len(n.linkIDs))
+		slices.SortFunc(n.Children, func(a, b *TagNode) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	slices.SortFunc(roots, func(a, b *TagNode) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return roots
+}
+
+// tagLineage returns a nested tag followed by each of its ancestors, so
+// "dev/go/testing" yields "dev/go/testing", "dev/go" and "dev".
+func tagLineage(tag string) []string {
+	lineage := []string{tag}
+	for i := strings.LastIndex(tag, "/"); i >= 0; i = strings.LastIndex(tag, "/") {
+		tag = tag[:i]
+		lineage = append(lineage, tag)
+	}
+
+	return lineage
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"linkstowr/internal/repository"
+)
+
+func TestTagListUnmarshal(t *testing.T) {
+	tests := map[string][]string{
+		`["Go", "#to_read", "go", " "]`: {"go", "to_read"},
+		`"Go, #to_read,go"`:             {"go", "to_read"},
+		`["#Dev//Go/ ", "dev / go"]`:    {"dev/go"},
+		`""`:                            {},
+		`[]`:                            {},
+	}
+
+	for input, expected := range tests {
+		var tags TagList
+		if err := json.Unmarshal([]byte(input), &tags); err != nil {
+			t.Errorf("json.Unmarshal(%s) error = %v", input, err)
+			continue
+		}
+		if !reflect.DeepEqual([]string(tags), expected) {
+			t.Errorf("json.Unmarshal(%s) = %v, expected %v", input, tags, expected)
+		}
+	}
+
+	var tags TagList
+	if err := json.Unmarshal([]byte(`42`), &tags); err == nil {
+		t.Errorf("json.Unmarshal(42) expected error")
+	}
+}
+
+func TestBuildTagTree(t *testing.T) {
+	tags := []repository.ListTagsWithCountsRow{
+		{ID: 1, Name: "dev/go", LinkCount: 2, LastUsedAt: sql.NullInt64{Int64: 100, Valid: true}},
+		{ID: 2, Name: "dev/go/testing", LinkCount: 1, LastUsedAt: sql.NullInt64{Int64: 200, Valid: true}},
+		{ID: 3, Name: "reading", LinkCount: 0},
+	}
+	tagLinks := []repository.ListTagLinksRow{
+		{Name: "dev/go", LinkID: 10},
+		{Name: "dev/go", LinkID: 11},
+		{Name: "dev/go/testing", LinkID: 10},
+	}
+
+	roots := buildTagTree(tags, tagLinks)
+	if len(roots) != 2 || roots[0].Path != "dev" || roots[1].Path != "reading" {
+		t.Fatalf("buildTagTree() roots = %v, expected dev and reading", roots)
+	}
+
+	dev := roots[0]
+	if dev.ID != nil || dev.LinkCount != 0 || dev.TotalCount != 2 {
+		t.Errorf("dev = %+v, expected implicit node with total count 2", dev)
+	}
+	if dev.LastUsedAt == nil || dev.LastUsedAt.Unix() != 200 {
+		t.Errorf("dev.LastUsedAt = %v, expected rolled up from dev/go/testing", dev.LastUsedAt)
+	}
+
+	golang := dev.Children[0]
+	if golang.Name != "go" || *golang.ID != 1 || golang.LinkCount != 2 || golang.TotalCount != 2 {
+		t.Errorf("dev/go = %+v, expected id 1 with link count 2 and total count 2", golang)
+	}
+
+	leaf := golang.Children[0]
+	if leaf.Path != "dev/go/testing" || leaf.TotalCount != 1 || len(leaf.Children) != 0 {
+		t.Errorf("dev/go/testing = %+v, expected leaf with total count 1", leaf)
+	}
+}
+
+func TestTagLineage(t *testing.T) {
+	expected := []string{"dev/go/testing", "dev/go", "dev"}
+	if actual := tagLineage("dev/go/testing"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("tagLineage() = %v, expected %v", actual, expected)
+	}
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/linktemplate"
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// templateTimeout and maxTemplateOutput limit a single export, across
+	// all links for per-link templates.
+	templateTimeout   = 5 * time.Second
+	maxTemplateOutput = 10 << 20
+)
+
+type Template struct {
+	// ID is nil for built-in templates.
+	ID        *int64     `json:"id"`
+	Name      string     `json:"name"`
+	Body      string     `json:"body"`
+	PerLink   bool       `json:"per_link"`
+	Builtin   bool       `json:"builtin"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// templateData is what list templates are executed with. Per-link templates
+// are executed with a single Link.
+type templateData struct {
+	Links      []Link
+	ExportedAt time.Time
+}
+
+// sampleLinks are used to try out templates before saving them.
+var sampleLinks = []Link{
+	{
+		ID:           1,
+		URL:          "https://go.dev/",
+		Title:        "The Go Programming Language",
+		Note:         "Build simple, secure, scalable systems with Go",
+		BookmarkedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Tags:         []string{"dev/go", "languages"},
+	},
+	{
+		ID:           2,
+		URL:          "https://sqlite.org/",
+		Title:        "SQLite Home Page",
+		BookmarkedAt: time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC),
+		Tags:         []string{},
+	},
+}
+
+func (s *Server) listTemplatesHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	templates, err := s.repository.ListTemplates(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	templatesResponse := make([]Template, 0, len(linktemplate.Builtins)+len(templates))
+
+	for _, def := range linktemplate.Builtins {
+		templatesResponse = append(templatesResponse, Template{
+			Name:    def.Name,
+			Body:    def.Body,
+			PerLink: def.PerLink,
+			Builtin: true,
+		})
+	}
+
+	for _, template := range templates {
+		templatesResponse = append(templatesResponse, newTemplate(repository.GetTemplateRow(template)))
+	}
+
+	return c.JSON(http.StatusOK, templatesResponse)
+}
+
+func (s *Server) createTemplateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var createTemplatePayload struct {
+		Name    string `json:"name" validate:"required,max=100"`
+		Body    string `json:"body" validate:"required,max=65536"`
+		PerLink bool   `json:"per_link"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&createTemplatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(createTemplatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	name := strings.TrimSpace(createTemplatePayload.Name)
+
+	err = validateTemplate(c.Request().Context(), linktemplate.Definition{
+		Name:    name,
+		Body:    createTemplatePayload.Body,
+		PerLink: createTemplatePayload.PerLink,
+	})
+	if err != nil {
+		return err
+	}
+
+	template, err := s.repository.CreateTemplate(c.Request().Context(), repository.CreateTemplateParams{
+		UserID:  userID,
+		Name:    name,
+		Body:    createTemplatePayload.Body,
+		PerLink: createTemplatePayload.PerLink,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return echo.NewHTTPError(http.StatusConflict, "Template already exists")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, newTemplate(repository.GetTemplateRow(template)))
+}
+
+func (s *Server) getTemplateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	templateID, err := getTemplateIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	template, err := s.repository.GetTemplate(c.Request().Context(), repository.GetTemplateParams{
+		ID:     templateID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newTemplate(template))
+}
+
+func (s *Server) updateTemplateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	templateID, err := getTemplateIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	var updateTemplatePayload struct {
+		Name    *string `json:"name" validate:"omitempty,min=1,max=100"`
+		Body    *string `json:"body" validate:"omitempty,min=1,max=65536"`
+		PerLink *bool   `json:"per_link"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&updateTemplatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(updateTemplatePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	if updateTemplatePayload.Name != nil {
+		name := strings.TrimSpace(*updateTemplatePayload.Name)
+		updateTemplatePayload.Name = &name
+	}
+
+	ctx := c.Request().Context()
+
+	var template repository.UpdateTemplateRow
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		existing, err := q.GetTemplate(ctx, repository.GetTemplateParams{
+			ID:     templateID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The template is validated as it will be after the update
+		def := linktemplate.Definition{
+			Name:    existing.Name,
+			Body:    existing.Body,
+			PerLink: existing.PerLink,
+		}
+		if updateTemplatePayload.Name != nil {
+			def.Name = *updateTemplatePayload.Name
+		}
+		if updateTemplatePayload.Body != nil {
+			def.Body = *updateTemplatePayload.Body
+		}
+		if updateTemplatePayload.PerLink != nil {
+			def.PerLink = *updateTemplatePayload.PerLink
+		}
+
+		if err := validateTemplate(ctx, def); err != nil {
+			return err
+		}
+
+		template, err = q.UpdateTemplate(ctx, repository.UpdateTemplateParams{
+			Name:    toNullString(updateTemplatePayload.Name),
+			Body:    toNullString(updateTemplatePayload.Body),
+			PerLink: toNullBool(updateTemplatePayload.PerLink),
+			ID:      templateID,
+			UserID:  userID,
+		})
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+		}
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return echo.NewHTTPError(http.StatusConflict, "Template already exists")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newTemplate(repository.GetTemplateRow(template)))
+}
+
+func (s *Server) deleteTemplateHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	templateID, err := getTemplateIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.repository.DeleteTemplate(c.Request().Context(), repository.DeleteTemplateParams{
+		ID:     templateID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// validateTemplate parses a template and executes it against sample links,
+// so that templates that can't be rendered are rejected when they are saved
+// rather than when they are used.
+func validateTemplate(ctx context.Context, def linktemplate.Definition) error {
+	if _, ok := linktemplate.Builtin(def.Name); ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Template name is reserved for a built-in template")
+	}
+
+	tmpl, err := linktemplate.Parse(def)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid template: "+err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, templateTimeout)
+	defer cancel()
+
+	var data any = templateData{Links: sampleLinks, ExportedAt: time.Now().UTC()}
+	if def.PerLink {
+		data = sampleLinks[0]
+	}
+
+	if _, err := linktemplate.Execute(ctx, tmpl, data, maxTemplateOutput); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid template: "+err.Error())
+	}
+
+	return nil
+}
+
+func newTemplate(template repository.GetTemplateRow) Template {
+	return Template{
+		ID:        &template.ID,
+		Name:      template.Name,
+		Body:      template.Body,
+		PerLink:   template.PerLink,
+		CreatedAt: &template.CreatedAt,
+		UpdatedAt: &template.UpdatedAt,
+	}
+}
+
+func getTemplateIDFromParam(c echo.Context) (int64, error) {
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid Template ID")
+	}
+
+	return templateID, nil
+}
+
+func toNullBool(value *bool) sql.NullBool {
+	if value == nil {
+		return sql.NullBool{}
+	}
+
+	return sql.NullBool{Bool: *value, Valid: true}
+}
@@ -106,3 +106,11 @@ func getUserIDFromContext(c echo.Context) (int64, error) {
 
 	return strconv.ParseInt(userID, 10, 64)
 }
+
+// getTokenIDFromContext returns the ID of the API token used to authenticate
+// the request. It reports false for requests authenticated with a JWT.
+func getTokenIDFromContext(c echo.Context) (int64, bool) {
+	tokenID, ok := c.Get("tokenID").(int64)
+
+	return tokenID, ok
+}
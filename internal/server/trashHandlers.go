@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"linkstowr/internal/repository"
+
+	"github.com/labstack/echo/v4"
+)
+
+// trashPurgeInterval is how often links past the trash retention are purged.
+const trashPurgeInterval = time.Hour
+
+type TrashedLink struct {
+	Link
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func (s *Server) listTrashHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	limit, err := parsePageLimit(c)
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	if offsetParam := c.QueryParam("offset"); offsetParam != "" {
+		offset, err = strconv.Atoi(offsetParam)
+		if err != nil || offset < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid offset")
+		}
+	}
+
+	links, err := s.repository.ListTrash(c.Request().Context(), repository.ListTrashParams{
+		UserID: userID,
+		Limit:  int64(limit),
+		Offset: int64(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	linksResponse := make([]TrashedLink, 0, len(links))
+
+	for _, link := range links {
+		linksResponse = append(linksResponse, TrashedLink{
+			Link: Link{
+				ID:           link.ID,
+				URL:          link.Url,
+				Title:        link.Title,
+				Note:         link.Note.String,
+				BookmarkedAt: link.BookmarkedAt,
+				Tags:         splitTags(link.Tags.String),
+			},
+			DeletedAt: link.DeletedAt.Time,
+		})
+	}
+
+	return c.JSON(http.StatusOK, linksResponse)
+}
+
+// restoreLinkHandler moves a link out of the trash. It fails if the user has
+// saved the same URL again in the meantime. Links that sync consumers were
+// done with stay in the library, rather than being claimed or pruned again.
+func (s *Server) restoreLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	restored, err := s.repository.RestoreLink(c.Request().Context(), repository.RestoreLinkParams{
+		ID:     linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return echo.NewHTTPError(http.StatusConflict, "A link with the same URL already exists")
+		}
+
+		return err
+	}
+
+	if restored == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Link not found in trash")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// purgeLinkHandler permanently deletes a link that is in the trash.
+func (s *Server) purgeLinkHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	linkID, err := getLinkIDFromParam(c)
+	if err != nil {
+		return err
+	}
+
+	purged, err := s.repository.PurgeLink(c.Request().Context(), repository.PurgeLinkParams{
+		ID:     linkID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if purged == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Link not found in trash")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// emptyTrashHandler permanently deletes all links in the user's trash.
+func (s *Server) emptyTrashHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	purged, err := s.repository.EmptyTrash(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+		"purged":  purged,
+	})
+}
+
+// purgeTrash permanently deletes links that have been in the trash for longer
+// than the trash retention, every trashPurgeInterval until ctx is done.
+func (s *Server) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-s.trashRetention).Unix()
+		if _, err := s.repository.PurgeDeletedLinks(ctx, cutoff); err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,538 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"linkstowr/internal/auth"
+	"linkstowr/internal/repository"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// totpIssuer names the account in authenticator apps.
+	totpIssuer = "LinkStowr"
+
+	// recoveryCodeCount is how many recovery codes a user gets at a time.
+	recoveryCodeCount = 10
+
+	// mfaChallengeTTL is how long a user has to enter their code after
+	// signing in with their password.
+	mfaChallengeTTL = 5 * time.Minute
+
+	// maxMFAChallengeAttempts is how many wrong codes an MFA token takes
+	// before the user has to sign in again.
+	maxMFAChallengeAttempts = 5
+
+	// maxMFAFailures is how many wrong codes in a row a user can enter,
+	// across all MFA tokens, before their second factor is locked.
+	maxMFAFailures = 5
+
+	// mfaLockout is how long the first lock lasts. Every further wrong code
+	// doubles it, up to maxMFALockout.
+	mfaLockout    = 15 * time.Minute
+	maxMFALockout = 24 * time.Hour
+)
+
+func (s *Server) twoFactorStatusHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	ctx := c.Request().Context()
+
+	totp, err := s.repository.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	recoveryCodes, err := s.repository.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"enabled":                  totp.TotpEnabledAt.Valid,
+		"recovery_codes_remaining": recoveryCodes,
+	})
+}
+
+// enrollTwoFactorHandler starts enrolling an authenticator app. Two-factor
+// authentication is only turned on once a first code is confirmed, so an
+// enrollment that is never finished changes nothing.
+func (s *Server) enrollTwoFactorHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var enrollPayload struct {
+		Password string `json:"password" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&enrollPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(enrollPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := s.checkCurrentPassword(ctx, userID, enrollPayload.Password)
+	if err != nil {
+		return err
+	}
+
+	if user.TotpEnabledAt.Valid {
+		return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	err = s.repository.SetUserTOTPSecret(ctx, repository.SetUserTOTPSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		ID:         userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(totpIssuer, user.Username, secret),
+	})
+}
+
+// confirmTwoFactorHandler turns two-factor authentication on with a first
+// code from the enrolled authenticator, and returns the recovery codes. They
+// are only ever shown here.
+func (s *Server) confirmTwoFactorHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var confirmPayload struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&confirmPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(confirmPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	var recoveryCodes []string
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		totp, err := q.GetUserTOTP(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if totp.TotpEnabledAt.Valid {
+			return echo.NewHTTPError(http.StatusConflict, "Two-factor authentication is already enabled")
+		}
+		if !totp.TotpSecret.Valid {
+			return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication enrollment hasn't been started")
+		}
+
+		ok, err := useTOTPCode(ctx, q, userID, totp.TotpSecret.String, confirmPayload.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid code")
+		}
+
+		_, err = q.EnableUserTOTP(ctx, repository.EnableUserTOTPParams{
+			TotpEnabledAt: sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true},
+			ID:            userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		recoveryCodes, err = replaceRecoveryCodes(ctx, q, userID)
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success":        true,
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// disableTwoFactorHandler turns two-factor authentication off. It takes the
+// password and a code, so that a stolen access token isn't enough.
+func (s *Server) disableTwoFactorHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var disablePayload struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&disablePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(disablePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := s.checkCurrentPassword(ctx, userID, disablePayload.Password)
+	if err != nil {
+		return err
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication isn't enabled")
+	}
+
+	var ok bool
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		var err error
+		ok, err = verifySecondFactor(ctx, q, userID, disablePayload.Code)
+		// The wrong code has to be counted, so this isn't an error
+		if err != nil || !ok {
+			return err
+		}
+
+		err = q.SetUserTOTPSecret(ctx, repository.SetUserTOTPSecretParams{
+			ID: userID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.DeleteRecoveryCodes(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid code")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success": true,
+	})
+}
+
+// regenerateRecoveryCodesHandler replaces the user's recovery codes, used or
+// not, with new ones.
+func (s *Server) regenerateRecoveryCodesHandler(c echo.Context) error {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "User ID not found in context")
+	}
+
+	var regeneratePayload struct {
+		Password string `json:"password" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&regeneratePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(regeneratePayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+
+	user, err := s.checkCurrentPassword(ctx, userID, regeneratePayload.Password)
+	if err != nil {
+		return err
+	}
+
+	if !user.TotpEnabledAt.Valid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Two-factor authentication isn't enabled")
+	}
+
+	var recoveryCodes []string
+	var ok bool
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		var err error
+		ok, err = verifySecondFactor(ctx, q, userID, regeneratePayload.Code)
+		// The wrong code has to be counted, so this isn't an error
+		if err != nil || !ok {
+			return err
+		}
+
+		recoveryCodes, err = replaceRecoveryCodes(ctx, q, userID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid code")
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"success":        true,
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// mfaHandler finishes signing in a user with two-factor authentication, given
+// the MFA token from signinHandler and a TOTP or recovery code.
+func (s *Server) mfaHandler(c echo.Context) error {
+	var mfaPayload struct {
+		MFAToken string `json:"mfa_token" validate:"required"`
+		Code     string `json:"code" validate:"required"`
+	}
+
+	err := json.NewDecoder(c.Request().Body).Decode(&mfaPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+	}
+
+	v := validator.New()
+	err = v.Struct(mfaPayload)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Validation failed: "+err.Error())
+	}
+
+	ctx := c.Request().Context()
+	now := time.Now().UTC()
+
+	var user repository.GetUserByIDRow
+	var ok bool
+	err = s.withTx(ctx, func(q *repository.Queries) error {
+		challenge, err := q.GetMFAChallenge(ctx, auth.HashSecretToken(mfaPayload.MFAToken))
+		if err != nil {
+			return err
+		}
+
+		if challenge.Attempts >= maxMFAChallengeAttempts || !now.Before(challenge.ExpiresAt) {
+			return sql.ErrNoRows
+		}
+
+		user, err = q.GetUserByID(ctx, challenge.UserID)
+		if err != nil {
+			return err
+		}
+
+		ok, err = verifySecondFactor(ctx, q, user.ID, mfaPayload.Code)
+		if err != nil {
+			return err
+		}
+
+		// The failed attempt has to be committed, so this isn't an error
+		if !ok {
+			return q.IncrementMFAChallengeAttempts(ctx, challenge.ID)
+		}
+
+		deleted, err := q.DeleteMFAChallenge(ctx, challenge.ID)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return sql.ErrNoRows
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired MFA token")
+		}
+
+		return err
+	}
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid code")
+	}
+
+	tokens, err := s.startSession(c, user.ID, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// startMFAChallenge stores a short-lived MFA token for a user who signed in
+// with their password and has yet to enter their code.
+func (s *Server) startMFAChallenge(ctx context.Context, userID int64) (echo.Map, error) {
+	token, tokenHash, err := auth.NewSecretToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.CreateMFAChallenge(ctx, repository.CreateMFAChallengeParams{
+		UserID:    userID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().UTC().Add(mfaChallengeTTL).Truncate(time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return echo.Map{
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_in":   int64(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, uses up a recovery
+// code. It reports false for users without two-factor authentication. Wrong
+// codes are counted per user, so that signing in again doesn't give more
+// guesses, and the transaction has to be committed even when it reports
+// false. Too many in a row lock the second factor for a while.
+func verifySecondFactor(ctx context.Context, q *repository.Queries, userID int64, code string) (bool, error) {
+	totp, err := q.GetUserTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if !totp.TotpEnabledAt.Valid {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+
+	// Locked users get no answer, even for the right code
+	if totp.MfaLockedUntil.Valid && now.Before(totp.MfaLockedUntil.Time) {
+		return false, echo.NewHTTPError(http.StatusTooManyRequests, "Too many wrong codes, try again later")
+	}
+
+	ok, err := useTOTPCode(ctx, q, userID, totp.TotpSecret.String, code)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		used, err := q.UseRecoveryCode(ctx, repository.UseRecoveryCodeParams{
+			UsedAt:   sql.NullTime{Time: now.Truncate(time.Second), Valid: true},
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return false, err
+		}
+		ok = used > 0
+	}
+
+	if ok {
+		return true, q.ResetMFAFailures(ctx, userID)
+	}
+
+	failures, err := q.RecordMFAFailure(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if failures >= maxMFAFailures {
+		err = q.LockMFA(ctx, repository.LockMFAParams{
+			MfaLockedUntil: sql.NullTime{Time: now.Add(mfaLockoutDuration(failures)), Valid: true},
+			ID:             userID,
+		})
+	}
+
+	return false, err
+}
+
+// mfaLockoutDuration is how long a user's second factor is locked after
+// failures wrong codes in a row.
+func mfaLockoutDuration(failures int64) time.Duration {
+	return min(mfaLockout<<min(failures-maxMFAFailures, 10), maxMFALockout)
+}
+
+// useTOTPCode checks a TOTP code and records its period, so that each code
+// works once.
+func useTOTPCode(ctx context.Context, q *repository.Queries, userID int64, secret string, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	used, err := q.UseTOTPStep(ctx, repository.UseTOTPStepParams{
+		Step: step,
+		ID:   userID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return used > 0, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores the hashes
+// of new ones, which it returns.
+func replaceRecoveryCodes(ctx context.Context, q *repository.Queries, userID int64) ([]string, error) {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := auth.NewRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		err = q.CreateRecoveryCode(ctx, repository.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
@@ -0,0 +1,77 @@
+//go:build sqlite_fts5
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"linkstowr/internal/auth"
+)
+
+func TestMFAFailuresCountedPerUser(t *testing.T) {
+	h := newTestServer(t).RegisterRoutes()
+	jwt := signupTestUser(t, h, "mfa")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	if code := testRequest(t, h, http.MethodPost, "/api/account/2fa/enroll", jwt, `{"password":"password"}`, &enrollment); code != http.StatusOK {
+		t.Fatalf("POST /api/account/2fa/enroll = %d", code)
+	}
+
+	totpCode, err := auth.TOTPCode(enrollment.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := testRequest(t, h, http.MethodPost, "/api/account/2fa/confirm", jwt, `{"code":"`+totpCode+`"}`, nil); code != http.StatusOK {
+		t.Fatalf("POST /api/account/2fa/confirm = %d", code)
+	}
+
+	// signin signs in with the password and returns a new MFA token
+	signin := func() string {
+		var challenge struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		if code := testRequest(t, h, http.MethodPost, "/signin", "", `{"username":"mfa","password":"password"}`, &challenge); code != http.StatusOK {
+			t.Fatalf("POST /signin = %d", code)
+		}
+
+		return challenge.MFAToken
+	}
+
+	// Each MFA token takes fewer wrong codes than the user's limit
+	for range maxMFAFailures {
+		body := `{"mfa_token":"` + signin() + `","code":"wrong"}`
+		if code := testRequest(t, h, http.MethodPost, "/auth/mfa", "", body, nil); code != http.StatusUnauthorized {
+			t.Fatalf("POST /auth/mfa with a wrong code = %d, expected 401", code)
+		}
+	}
+
+	// The code of the next period hasn't been used, but the user is locked
+	totpCode, err = auth.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"mfa_token":"` + signin() + `","code":"` + totpCode + `"}`
+	if code := testRequest(t, h, http.MethodPost, "/auth/mfa", "", body, nil); code != http.StatusTooManyRequests {
+		t.Errorf("POST /auth/mfa after %d wrong codes = %d, expected 429", maxMFAFailures, code)
+	}
+}
+
+func TestMFALockoutDuration(t *testing.T) {
+	tests := map[int64]time.Duration{
+		maxMFAFailures:      mfaLockout,
+		maxMFAFailures + 1:  2 * mfaLockout,
+		maxMFAFailures + 3:  8 * mfaLockout,
+		maxMFAFailures + 50: maxMFALockout,
+	}
+
+	for failures, expected := range tests {
+		if actual := mfaLockoutDuration(failures); actual != expected {
+			t.Errorf("mfaLockoutDuration(%d) = %v, expected %v", failures, actual, expected)
+		}
+	}
+}